@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"vwhitteron/homekit-garage-shutter/control"
+	"vwhitteron/homekit-garage-shutter/hardware"
+
+	"github.com/spf13/viper"
+)
+
+type command struct {
+	name  string
+	args  string
+	usage string
+	run   func(args []string) error
+}
+
+func commands() []command {
+	return []command{
+		{"serve", "", "run the HomeKit daemon (default)", serve},
+		{"status", "", "show the shutter state", daemonCommand("status", directStatus)},
+		{"open", "", "open the shutter", daemonCommand("open", directOpen)},
+		{"close", "", "close the shutter", daemonCommand("close", directClose)},
+		{"cancel", "", "cancel a close during its warning (daemon only)", daemonCommand("cancel", nil)},
+		{"lock", "", "lock the shutter (daemon only)", daemonCommand("lock", nil)},
+		{"unlock", "", "unlock the shutter (daemon only)", daemonCommand("unlock", nil)},
+		{"lock-history", "", "show who locked and unlocked the shutter (daemon only)", daemonCommand("lock-history", nil)},
+		{"press-relay", "N", "press relay N for the switch hold time", daemonCommand("press-relay", directPressRelay)},
+		{"read-inputs", "", "show the level of every input", daemonCommand("read-inputs", directReadInputs)},
+		{"selftest", "[-dry-run] [-yes] [-observe 20s]", "check the relay and input wiring", selfTest},
+		{"calibrate", "[-manual] [-timeout 2m]", "learn the contact polarity and travel times", calibrate},
+		{"pairing-info", "", "show the HomeKit setup code and QR code", pairingInfo},
+		{"pairing list", "", "list the paired HomeKit controllers", pairingList},
+		{"pairing remove", "ID", "remove a paired controller", pairingRemove},
+		{"pairing reset", "[-yes]", "remove every pairing and the HomeKit identity", pairingReset},
+		{"config validate", "", "check the config file for problems", configValidate},
+		{"config show", "", "show the effective config", daemonCommand("config-show", directConfigShow)},
+		{"version", "", "show the version", version},
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [args]\n\nCommands:\n", filepath.Base(os.Args[0]))
+
+	for _, cmd := range commands() {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.usage)
+	}
+}
+
+// runCommand runs the command named by the leading args. Commands may be made
+// of several words, such as "config show".
+func runCommand(args []string) error {
+	for _, cmd := range commands() {
+		words := strings.Fields(cmd.name)
+
+		if len(args) < len(words) || strings.Join(args[:len(words)], " ") != cmd.name {
+			continue
+		}
+
+		return cmd.run(args[len(words):])
+	}
+
+	usage()
+
+	return fmt.Errorf("unknown command %q", strings.Join(args, " "))
+}
+
+func controlSocket(opts hardware.ShutterOptions) string {
+	baseDir := opts.BaseDirectory
+	if baseDir == "" {
+		baseDir = "."
+	}
+
+	return filepath.Join(baseDir, "control.sock")
+}
+
+// daemonCommand returns a command that is sent to the running daemon. When the
+// daemon is not running the direct function is used to access the hardware
+// instead, if there is one.
+func daemonCommand(name string, direct func(opts hardware.ShutterOptions, args []string) (string, error)) func(args []string) error {
+	return func(args []string) error {
+		opts, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		output, err := control.Call(controlSocket(opts), name, args...)
+		if errors.Is(err, control.ErrNotRunning) {
+			if direct == nil {
+				return err
+			}
+
+			output, err = direct(opts, args)
+		}
+
+		if output != "" {
+			fmt.Println(output)
+		}
+
+		return err
+	}
+}
+
+// withHardware opens the hardware for the duration of fn.
+func withHardware(opts hardware.ShutterOptions, fn func(hw *hardware.Hardware) (string, error)) (string, error) {
+	if err := opts.Validate(); err != nil {
+		return "", fmt.Errorf("invalid configuration:\n%s", formatConfigError(err))
+	}
+
+	hw, err := hardware.NewHardware(opts)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := fn(hw)
+
+	return output, errors.Join(err, hw.Halt())
+}
+
+func parseRelay(args []string) (uint, error) {
+	if len(args) != 1 {
+		return 0, errors.New("expected a relay number")
+	}
+
+	relay, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid relay %q", args[0])
+	}
+
+	return uint(relay), nil
+}
+
+func formatStatus(status hardware.ShutterStatus) string {
+	return fmt.Sprintf("position=%s current=%s target=%s locked=%t", status.Position, status.Current, status.Target, status.Locked)
+}
+
+func directStatus(opts hardware.ShutterOptions, _ []string) (string, error) {
+	return withHardware(opts, func(hw *hardware.Hardware) (string, error) {
+		return fmt.Sprintf("position=%s (daemon not running)", hw.Position()), nil
+	})
+}
+
+func directOpen(opts hardware.ShutterOptions, _ []string) (string, error) {
+	return directPressRelay(opts, []string{strconv.FormatUint(uint64(opts.OpenButtonRelay), 10)})
+}
+
+func directClose(opts hardware.ShutterOptions, _ []string) (string, error) {
+	return directPressRelay(opts, []string{strconv.FormatUint(uint64(opts.CloseButtonRelay), 10)})
+}
+
+func directPressRelay(opts hardware.ShutterOptions, args []string) (string, error) {
+	relay, err := parseRelay(args)
+	if err != nil {
+		return "", err
+	}
+
+	return withHardware(opts, func(hw *hardware.Hardware) (string, error) {
+		if err := hw.PressRelay(relay, opts.SwitchHold()); err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("relay %d pressed (daemon not running)", relay), nil
+	})
+}
+
+func directReadInputs(opts hardware.ShutterOptions, _ []string) (string, error) {
+	return withHardware(opts, func(hw *hardware.Hardware) (string, error) {
+		return hardware.FormatLevels(hw.ReadInputs()), nil
+	})
+}
+
+func directConfigShow(opts hardware.ShutterOptions, _ []string) (string, error) {
+	var b strings.Builder
+
+	printConfig(&b, opts)
+
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// requireDaemonStopped returns an error if the daemon is running, for commands
+// that need the hardware to themselves.
+func requireDaemonStopped(opts hardware.ShutterOptions) error {
+	if _, err := control.Call(controlSocket(opts), "status"); !errors.Is(err, control.ErrNotRunning) {
+		return errors.New("the daemon is running, stop it first")
+	}
+
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal.
+func confirm(in *bufio.Reader) func(prompt string) bool {
+	return func(prompt string) bool {
+		fmt.Printf("%s [y/N] ", prompt)
+
+		answer, _ := in.ReadString('\n')
+
+		return strings.EqualFold(strings.TrimSpace(answer), "y")
+	}
+}
+
+func selfTest(args []string) error {
+	flags := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "do not press any relays")
+	yes := flags.Bool("yes", false, "do not ask for confirmation")
+	observe := flags.Duration("observe", 20*time.Second, "how long to watch the inputs after each step")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	opts, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := requireDaemonStopped(opts); err != nil {
+		return err
+	}
+
+	ask := confirm(bufio.NewReader(os.Stdin))
+	if *yes {
+		ask = func(prompt string) bool {
+			fmt.Println(prompt + " yes")
+
+			return true
+		}
+	}
+
+	_, err = withHardware(opts, func(hw *hardware.Hardware) (string, error) {
+		return "", hw.SelfTest(opts, hardware.SelfTestOptions{
+			DryRun:  *dryRun,
+			Observe: *observe,
+			Confirm: ask,
+			Out:     os.Stdout,
+		})
+	})
+
+	return err
+}
+
+func calibrate(args []string) error {
+	flags := flag.NewFlagSet("calibrate", flag.ContinueOnError)
+	manual := flags.Bool("manual", false, "operate the shutter with its remote instead of the relays")
+	timeout := flags.Duration("timeout", 2*time.Minute, "how long to wait for the shutter to open or close")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	opts, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := requireDaemonStopped(opts); err != nil {
+		return err
+	}
+
+	in := bufio.NewReader(os.Stdin)
+
+	var cal hardware.Calibration
+
+	_, err = withHardware(opts, func(hw *hardware.Hardware) (string, error) {
+		cal, err = hw.Calibrate(opts, hardware.CalibrateOptions{
+			Manual:  *manual,
+			Timeout: *timeout,
+			Wait: func(prompt string) {
+				fmt.Print(prompt + " ")
+				_, _ = in.ReadString('\n')
+			},
+			Out: os.Stdout,
+		})
+
+		return "", err
+	})
+	if err != nil {
+		return err
+	}
+
+	keys := []string{"OpenContactActiveLow", "CloseContactActiveLow", "OpenTravelMs", "CloseTravelMs"}
+	values := map[string]any{
+		"OpenContactActiveLow":  cal.OpenContactActiveLow,
+		"CloseContactActiveLow": cal.CloseContactActiveLow,
+		"OpenTravelMs":          cal.OpenTravel.Milliseconds(),
+		"CloseTravelMs":         cal.CloseTravel.Milliseconds(),
+	}
+
+	for _, key := range keys {
+		fmt.Printf("%s = %s\n", key, formatConfigValue(values[key]))
+	}
+
+	if err := updateConfigFile(viper.ConfigFileUsed(), keys, values); err != nil {
+		return fmt.Errorf("writing calibration to config: %w", err)
+	}
+
+	fmt.Printf("calibration written to %s\n", viper.ConfigFileUsed())
+
+	return nil
+}
+
+func configValidate(_ []string) error {
+	opts, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := opts.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%s", formatConfigError(err))
+	}
+
+	fmt.Printf("%s: configuration is valid\n", viper.ConfigFileUsed())
+
+	return nil
+}
+
+func version(_ []string) error {
+	if BuildTime == "" {
+		BuildTime = time.Now().Format("2006-01-02_15:04:05")
+	}
+
+	fmt.Printf("homebridge-garage-shutter version %s (built %s)\n", Version, BuildTime)
+
+	return nil
+}
+
+// registerHandlers exposes the shutter on the control socket.
+func registerHandlers(server *control.Server, shutter *hardware.Shutter) {
+	server.Handle("status", func(_ []string) (string, error) {
+		return formatStatus(shutter.Status()), nil
+	})
+
+	server.Handle("open", func(_ []string) (string, error) {
+		return "open requested", shutter.Open(hardware.SourceAPI)
+	})
+
+	server.Handle("close", func(_ []string) (string, error) {
+		return "close requested", shutter.Close(hardware.SourceAPI)
+	})
+
+	server.Handle("cancel", func(_ []string) (string, error) {
+		return "close cancelled", shutter.CancelClose(hardware.SourceAPI)
+	})
+
+	server.Handle("lock", func(_ []string) (string, error) {
+		return "locked", shutter.Lock(hardware.SourceAPI)
+	})
+
+	server.Handle("unlock", func(_ []string) (string, error) {
+		return "unlocked", shutter.Unlock(hardware.SourceAPI)
+	})
+
+	server.Handle("lock-history", func(_ []string) (string, error) {
+		var lines []string
+		for _, event := range shutter.LockHistory() {
+			lines = append(lines, event.String())
+		}
+
+		if len(lines) == 0 {
+			return "no lock events", nil
+		}
+
+		return strings.Join(lines, "\n"), nil
+	})
+
+	server.Handle("press-relay", func(args []string) (string, error) {
+		relay, err := parseRelay(args)
+		if err != nil {
+			return "", err
+		}
+
+		if err := shutter.PressRelay(relay); err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("relay %d pressed", relay), nil
+	})
+
+	server.Handle("read-inputs", func(_ []string) (string, error) {
+		return hardware.FormatLevels(shutter.ReadInputs()), nil
+	})
+
+	server.Handle("config-show", func(_ []string) (string, error) {
+		return directConfigShow(shutter.Options(), nil)
+	})
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"vwhitteron/homekit-garage-shutter/hardware"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+func defaultOptions() hardware.ShutterOptions {
+	return hardware.ShutterOptions{
+		BaseDirectory:              "/opt/homekit-garage-shutter",
+		Board:                      hardware.BoardAutomationHat,
+		EnableHomekitLockSwitch:    true,
+		EnableHomekitLockMechanism: true,
+		EnableHomekitContactSensor: true,
+		LockWhenClosed:             true,
+		CloseWhenLocked:            true,
+		SwitchHoldMs:               500,
+		DebounceMs:                 5000,
+		MotionBlockMs:              5000,
+		AllowReverseWhileMoving:    false,
+
+		Name:         "Garage Shutter",
+		Manufacturer: "generic",
+		Model:        "",
+		SerialNumber: "",
+
+		HomekitPort: 40111,
+
+		OpenButtonRelay:   1,
+		CloseButtonRelay:  3,
+		OpenContactInput:  1,
+		CloseContactInput: 2,
+
+		LedBrightness:      16,
+		LedNightBrightness: 1,
+
+		MotorStartTimeoutMs: 3000,
+		MotorStallMs:        2000,
+
+		ShellyRelays:    1,
+		ShellyInputs:    1,
+		ShellyTimeoutMs: 2000,
+		ShellyRetries:   2,
+		ShellyPollMs:    1000,
+
+		MQTTClientID:     "homekit-garage-shutter",
+		MQTTPayloadOn:    "ON",
+		MQTTPayloadOff:   "OFF",
+		MQTTStaleSeconds: 900,
+
+		SerialDevice:   "/dev/ttyUSB0",
+		SerialBaud:     9600,
+		SerialProtocol: hardware.SerialProtocolLCUS,
+		SerialRelays:   2,
+
+		SimulatorTravelMs: 10000,
+	}
+}
+
+func setupConfig() {
+	viper.SetEnvPrefix("HOMEBRIDGE_GARAGE_SHUTTER")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(`.`, `_`))
+	viper.AutomaticEnv()
+
+	viper.SetConfigName("config")
+	viper.SetConfigType("toml")
+	viper.AddConfigPath(".")
+	viper.AddConfigPath("/boot/homekit-garage-shutter/")
+	viper.AddConfigPath("/etc/homekit-garage-shutter/")
+	viper.AddConfigPath("/opt/homekit-garage-shutter/")
+}
+
+// loadConfig reads the config file and returns the shutter options it
+// describes on top of the defaults.
+func loadConfig() (hardware.ShutterOptions, error) {
+	opts := defaultOptions()
+
+	if err := viper.ReadInConfig(); err != nil {
+		return opts, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	return unmarshalConfig()
+}
+
+// unmarshalConfig returns the shutter options for the config already read by
+// viper.
+func unmarshalConfig() (hardware.ShutterOptions, error) {
+	opts := defaultOptions()
+
+	if err := viper.Unmarshal(&opts); err != nil {
+		return opts, fmt.Errorf("unmarshal config: %w", err)
+	}
+
+	return opts, nil
+}
+
+// watchConfigFile reports the path of the config file each time it is written
+// or replaced. The directory is watched rather than the file so that editors
+// which save by renaming a new file into place are noticed too.
+func watchConfigFile(path string) (<-chan string, error) {
+	path = filepath.Clean(path)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+
+		return nil, err
+	}
+
+	changes := make(chan string)
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if filepath.Clean(event.Name) == path && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					changes <- event.Name
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				log.Printf("Config watch: status=failed error=%q\n", err)
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+func formatConfigError(err error) string {
+	return "  " + strings.ReplaceAll(err.Error(), "\n", "\n  ")
+}
+
+// formatConfigValue formats a value the way it is written in the config file.
+func formatConfigValue(value any) string {
+	if str, ok := value.(string); ok {
+		return fmt.Sprintf("%q", str)
+	}
+
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Slice {
+		items := make([]string, 0, v.Len())
+		for i := range v.Len() {
+			items = append(items, formatConfigValue(v.Index(i).Interface()))
+		}
+
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+
+	return fmt.Sprintf("%v", value)
+}
+
+// updateConfigFile sets keys in the config file while keeping the comments
+// and layout of the rest of the file. Keys missing from the file are added
+// before the first table.
+func updateConfigFile(path string, keys []string, values map[string]any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+
+	for _, key := range keys {
+		line := key + " = " + formatConfigValue(values[key])
+		keyRe := regexp.MustCompile(`^\s*` + regexp.QuoteMeta(key) + `\s*=`)
+
+		found := false
+		for i := range lines {
+			if keyRe.MatchString(lines[i]) {
+				lines[i] = line
+				found = true
+
+				break
+			}
+		}
+
+		if found {
+			continue
+		}
+
+		insert := len(lines)
+		for i := range lines {
+			if strings.HasPrefix(strings.TrimSpace(lines[i]), "[") {
+				insert = i
+
+				break
+			}
+		}
+
+		lines = append(lines[:insert], append([]string{line}, lines[insert:]...)...)
+	}
+
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644)
+}
+
+// secretOptions are the options whose values are not shown by config show.
+var secretOptions = map[string]bool{
+	"MQTTPassword": true,
+}
+
+// printConfig writes the options in the same format as the config file.
+// Lists of options are written as tables after the other keys and secrets
+// that are set are redacted.
+func printConfig(w io.Writer, opts hardware.ShutterOptions) {
+	v := reflect.ValueOf(opts)
+
+	var tables []int
+
+	for i := range v.NumField() {
+		field := v.Field(i)
+
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct {
+			tables = append(tables, i)
+
+			continue
+		}
+
+		name := v.Type().Field(i).Name
+		value := formatConfigValue(field.Interface())
+
+		if secretOptions[name] && !field.IsZero() {
+			value = formatConfigValue("REDACTED")
+		}
+
+		fmt.Fprintf(w, "%s = %s\n", name, value)
+	}
+
+	for _, i := range tables {
+		field := v.Field(i)
+
+		for j := range field.Len() {
+			fmt.Fprintf(w, "\n[[%s]]\n", v.Type().Field(i).Name)
+
+			table := field.Index(j)
+			for k := range table.NumField() {
+				fmt.Fprintf(w, "%s = %s\n", table.Type().Field(k).Name, formatConfigValue(table.Field(k).Interface()))
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// loadConfigFile reads the options from a config file at path.
+func loadConfigFile(t *testing.T, path string) map[string]any {
+	t.Helper()
+
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+
+	return v.AllSettings()
+}
+
+func TestFormatConfigValue(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{"Garage", `"Garage"`},
+		{uint(3), "3"},
+		{true, "true"},
+		{[]string{"GPIO5", "GPIO6"}, `["GPIO5", "GPIO6"]`},
+		{[]uint{1, 2}, "[1, 2]"},
+		{[]string(nil), "[]"},
+	}
+
+	for _, tt := range tests {
+		if got := formatConfigValue(tt.value); got != tt.want {
+			t.Errorf("formatConfigValue(%#v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateConfigFileLists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+
+	if err := os.WriteFile(path, []byte("Board = \"gpio\"\nGPIORelayPins = [\"GPIO17\"]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{"GPIORelayPins", "ExpanderInputPins"}
+	values := map[string]any{
+		"GPIORelayPins":     []string{"GPIO5", "GPIO6"},
+		"ExpanderInputPins": []uint{1, 2},
+	}
+
+	if err := updateConfigFile(path, keys, values); err != nil {
+		t.Fatalf("updateConfigFile() error = %v", err)
+	}
+
+	settings := loadConfigFile(t, path)
+
+	want := map[string]any{
+		"board":             "gpio",
+		"gpiorelaypins":     []any{"GPIO5", "GPIO6"},
+		"expanderinputpins": []any{int64(1), int64(2)},
+	}
+
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("config read back = %#v, want %#v", settings, want)
+	}
+}
+
+func TestPrintConfigReadsBack(t *testing.T) {
+	opts := defaultOptions()
+	opts.GPIORelayPins = []string{"GPIO5", "GPIO6"}
+	opts.GPIOInputPins = []string{"GPIO13"}
+	opts.ExpanderRelayPins = []uint{0, 1}
+	opts.ExpanderInputPins = []uint{8}
+	opts.MQTTRelayTopics = []string{"garage/relay"}
+	opts.MQTTInputTopics = []string{"garage/open", "garage/closed"}
+
+	var out bytes.Buffer
+	printConfig(&out, opts)
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.SetConfigFile(path)
+	t.Cleanup(viper.Reset)
+
+	got, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v\n%s", err, out.String())
+	}
+
+	if !reflect.DeepEqual(got, opts) {
+		t.Errorf("printed config read back as\n%+v\nwant\n%+v", got, opts)
+	}
+}
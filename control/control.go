@@ -0,0 +1,169 @@
+// Package control implements the local Unix socket used by the command line
+// to operate a running daemon.
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrNotRunning is returned by Call when no daemon is listening on the socket.
+var ErrNotRunning = errors.New("daemon is not running")
+
+type Request struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+type Response struct {
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HandlerFunc handles a single command and returns the text to print.
+type HandlerFunc func(args []string) (string, error)
+
+type Server struct {
+	path     string
+	listener net.Listener
+
+	mu       sync.Mutex
+	handlers map[string]HandlerFunc
+}
+
+func NewServer(path string) *Server {
+	return &Server{
+		path:     path,
+		handlers: map[string]HandlerFunc{},
+	}
+}
+
+func (s *Server) Handle(command string, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[command] = fn
+}
+
+// Listen opens the socket and serves requests in the background. A stale
+// socket left behind by a previous daemon is removed, but an error is returned
+// if another daemon is still listening on it.
+func (s *Server) Listen() error {
+	if conn, err := net.DialTimeout("unix", s.path, time.Second); err == nil {
+		conn.Close()
+
+		return fmt.Errorf("control socket %s is in use by another daemon", s.path)
+	}
+
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing stale control socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", s.path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(s.path, 0o660); err != nil {
+		listener.Close()
+
+		return err
+	}
+
+	s.listener = listener
+
+	log.Printf("Control socket: path=%s status=listening\n", s.path)
+
+	go s.serve()
+
+	return nil
+}
+
+func (s *Server) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+
+	return s.listener.Close()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Control socket: status=failed error=%q\n", err)
+			}
+
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var req Request
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		log.Printf("Control socket: status=invalid error=%q\n", err)
+
+		return
+	}
+
+	s.mu.Lock()
+	fn, ok := s.handlers[req.Command]
+	s.mu.Unlock()
+
+	var resp Response
+	if !ok {
+		resp.Error = fmt.Sprintf("unknown command %q", req.Command)
+	} else if output, err := fn(req.Args); err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Output = output
+	}
+
+	log.Printf("Control socket: command=%s args=%q\n", req.Command, req.Args)
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		log.Printf("Control socket: status=failed error=%q\n", err)
+	}
+}
+
+// Call sends a command to the daemon listening on path and returns its output.
+// ErrNotRunning is returned when there is no daemon to talk to; any other
+// failure to connect, such as a permission error, is returned as is.
+func Call(path string, command string, args ...string) (string, error) {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ECONNREFUSED) {
+		return "", ErrNotRunning
+	} else if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(Request{Command: command, Args: args}); err != nil {
+		return "", err
+	}
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return "", fmt.Errorf("reading daemon response: %w", err)
+	}
+
+	if resp.Error != "" {
+		return resp.Output, errors.New(resp.Error)
+	}
+
+	return resp.Output, nil
+}
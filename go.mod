@@ -4,16 +4,20 @@ go 1.22.6
 
 require (
 	github.com/brutella/hc v1.2.5
+	github.com/eclipse/paho.mqtt.golang v1.4.3
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/spf13/viper v1.20.1
+	golang.org/x/sys v0.29.0
 	periph.io/x/conn/v3 v3.6.9
 	periph.io/x/devices/v3 v3.6.12
 	periph.io/x/host/v3 v3.7.1
+	rsc.io/qr v0.2.0
 )
 
 require (
 	github.com/brutella/dnssd v1.2.1 // indirect
-	github.com/fsnotify/fsnotify v1.8.0 // indirect
 	github.com/go-viper/mapstructure/v2 v2.2.1 // indirect
+	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/miekg/dns v1.1.4 // indirect
 	github.com/pelletier/go-toml/v2 v2.2.3 // indirect
 	github.com/sagikazarmark/locafero v0.7.0 // indirect
@@ -28,7 +32,7 @@ require (
 	go.uber.org/multierr v1.9.0 // indirect
 	golang.org/x/crypto v0.32.0 // indirect
 	golang.org/x/net v0.33.0 // indirect
-	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/sync v0.10.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
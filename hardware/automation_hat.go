@@ -2,9 +2,12 @@ package hardware
 
 import (
 	"fmt"
+	"sync"
 
 	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/i2c"
 	"periph.io/x/conn/v3/i2c/i2creg"
+	"periph.io/x/devices/v3/ads1x15"
 	"periph.io/x/devices/v3/sn3218"
 	"periph.io/x/host/v3/rpi"
 )
@@ -32,20 +35,31 @@ var (
 
 type AutomationHatOpts struct {
 	AutoLeds bool
+	Leds     LedOptions
 }
 
 var AutomationHatDefaultOpts = AutomationHatOpts{
 	AutoLeds: true,
+	Leds: LedOptions{
+		Brightness:      0x01,
+		NightBrightness: 0x01,
+	},
 }
 
 // AutomationHat represents an Automation HAT
 type AutomationHat struct {
 	opts AutomationHatOpts
+	i2c  i2c.Bus
 
 	outputs []gpio.PinOut
 	relays  []gpio.PinOut
 	inputs  []gpio.PinIn
 	leds    *sn3218.Dev
+	status  *LedController
+
+	adcOnce sync.Once
+	adc     *ads1x15.Dev
+	adcErr  error
 }
 
 // NewAutomationHat returns a automationhat driver.
@@ -63,6 +77,7 @@ func NewAutomationHat(opts *AutomationHatOpts) (*AutomationHat, error) {
 
 	dev := &AutomationHat{
 		opts: *opts,
+		i2c:  i2cPort,
 
 		outputs: []gpio.PinOut{
 			rpi.P1_29, // GPIO 5
@@ -86,24 +101,44 @@ func NewAutomationHat(opts *AutomationHatOpts) (*AutomationHat, error) {
 	}
 
 	if dev.leds != nil && dev.opts.AutoLeds {
-		if err := dev.leds.WakeUp(); err != nil {
-			return nil, err
-		}
-
-		if err := dev.leds.SwitchAll(true); err != nil {
+		dev.status, err = newLedController(dev.leds, dev.opts.Leds)
+		if err != nil {
 			return nil, err
 		}
 
-		if err := dev.leds.Brightness(LedPower, 0x01); err != nil {
-			return nil, err
-		}
+		dev.mirrorLeds()
 	}
 
 	return dev, nil
 }
 
+// mirrorLeds wraps the pins so their LEDs follow the pin levels.
+func (d *AutomationHat) mirrorLeds() {
+	outputLeds := []int{LedOutput1, LedOutput2, LedOutput3}
+	for i, output := range d.outputs {
+		d.outputs[i] = &ledPinOut{PinOut: output, leds: d.status, on: outputLeds[i], off: -1}
+	}
+
+	relayLeds := [][2]int{{LedRelay1NO, LedRelay1NC}, {LedRelay2NO, LedRelay2NC}, {LedRelay3NO, LedRelay3NC}}
+	for i, relay := range d.relays {
+		d.relays[i] = &ledPinOut{PinOut: relay, leds: d.status, on: relayLeds[i][0], off: relayLeds[i][1]}
+		d.status.Set(relayLeds[i][1], true)
+	}
+
+	inputLeds := []int{LedInput1, LedInput2, LedInput3}
+	for i, input := range d.inputs {
+		d.inputs[i] = &ledPinIn{PinIn: input, leds: d.status, led: inputLeds[i]}
+	}
+}
+
+// Leds returns the status LED controller, which is nil when the board has no
+// LEDs or AutoLeds is disabled.
+func (d *AutomationHat) Leds() *LedController {
+	return d.status
+}
+
 func (d *AutomationHat) GetOutput(output uint) (gpio.PinOut, error) {
-	if output > uint(len(d.outputs))+1 {
+	if output == 0 || output > uint(len(d.outputs)) {
 		return nil, fmt.Errorf("invalid output %d", output)
 	}
 
@@ -111,7 +146,7 @@ func (d *AutomationHat) GetOutput(output uint) (gpio.PinOut, error) {
 }
 
 func (d *AutomationHat) GetRelay(relay uint) (gpio.PinOut, error) {
-	if relay > uint(len(d.relays))+1 {
+	if relay == 0 || relay > uint(len(d.relays)) {
 		return nil, fmt.Errorf("invalid relay %d", relay)
 	}
 
@@ -119,13 +154,31 @@ func (d *AutomationHat) GetRelay(relay uint) (gpio.PinOut, error) {
 }
 
 func (d *AutomationHat) GetInput(input uint) (gpio.PinIn, error) {
-	if input > uint(len(d.inputs))+1 {
-		return nil, fmt.Errorf("invalid relay %d", input)
+	if input == 0 || input > uint(len(d.inputs)) {
+		return nil, fmt.Errorf("invalid input %d", input)
 	}
 
 	return d.inputs[input-1], nil
 }
 
+func (d *AutomationHat) Relays() []Relay {
+	relays := make([]Relay, 0, len(d.relays))
+	for _, relay := range d.relays {
+		relays = append(relays, relay)
+	}
+
+	return relays
+}
+
+func (d *AutomationHat) Inputs() []Input {
+	inputs := make([]Input, 0, len(d.inputs))
+	for _, input := range d.inputs {
+		inputs = append(inputs, input)
+	}
+
+	return inputs
+}
+
 // Halt all internal devices.
 func (d *AutomationHat) Halt() error {
 	for _, output := range d.outputs {
@@ -0,0 +1,80 @@
+package hardware
+
+import (
+	"fmt"
+
+	"periph.io/x/conn/v3/physic"
+	"periph.io/x/devices/v3/ads1x15"
+)
+
+// The first three ADC channels on the Automation HAT are 24V tolerant and
+// divided down to the 3.3V range of the ADS1015; the fourth is a 3.3V input.
+const (
+	adcMaxVoltage      = 3300 * physic.MilliVolt
+	adcDividedScale    = 25.85 / 3.3
+	adcSampleFrequency = 100 * physic.Hertz
+)
+
+var adcChannels = []ads1x15.Channel{
+	ads1x15.Channel0,
+	ads1x15.Channel1,
+	ads1x15.Channel2,
+	ads1x15.Channel3,
+}
+
+// AnalogInput is an ADC channel of the Automation HAT.
+type AnalogInput struct {
+	name  string
+	pin   ads1x15.PinADC
+	scale float64
+}
+
+// GetAnalog returns ADC channel 1 to 4.
+func (d *AutomationHat) GetAnalog(channel uint) (*AnalogInput, error) {
+	if channel == 0 || channel > uint(len(adcChannels)) {
+		return nil, fmt.Errorf("invalid analog input %d", channel)
+	}
+
+	adc, err := d.getADC()
+	if err != nil {
+		return nil, err
+	}
+
+	pin, err := adc.PinForChannel(adcChannels[channel-1], adcMaxVoltage, adcSampleFrequency, ads1x15.BestQuality)
+	if err != nil {
+		return nil, err
+	}
+
+	scale := adcDividedScale
+	if channel == 4 {
+		scale = 1
+	}
+
+	return &AnalogInput{
+		name:  fmt.Sprintf("ADC%d", channel),
+		pin:   pin,
+		scale: scale,
+	}, nil
+}
+
+func (d *AutomationHat) getADC() (*ads1x15.Dev, error) {
+	d.adcOnce.Do(func() {
+		d.adc, d.adcErr = ads1x15.NewADS1015(d.i2c, &ads1x15.DefaultOpts)
+	})
+
+	return d.adc, d.adcErr
+}
+
+func (a *AnalogInput) Name() string {
+	return a.name
+}
+
+// Volts returns the voltage at the input terminal.
+func (a *AnalogInput) Volts() (float64, error) {
+	sample, err := a.pin.Read()
+	if err != nil {
+		return 0, fmt.Errorf("reading %s: %w", a.name, err)
+	}
+
+	return float64(sample.V) / float64(physic.Volt) * a.scale, nil
+}
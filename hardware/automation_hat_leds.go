@@ -0,0 +1,219 @@
+package hardware
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/devices/v3/sn3218"
+)
+
+const ledBlinkDuration = 200 * time.Millisecond
+
+type LedOptions struct {
+	Brightness      byte
+	NightBrightness byte
+	// NightStart and NightEnd are the time of day, as 15:04, between which
+	// NightBrightness is used. Night dimming is disabled when either is empty.
+	NightStart string
+	NightEnd   string
+}
+
+// LedController drives the Automation HAT status LEDs. All methods are no-ops
+// when the board has no LEDs, such as the Automation HAT Mini.
+type LedController struct {
+	leds *sn3218.Dev
+	opts LedOptions
+
+	mu         sync.Mutex
+	night      bool
+	nightStart time.Duration
+	nightEnd   time.Duration
+	flashUntil map[int]time.Time
+}
+
+func newLedController(leds *sn3218.Dev, opts LedOptions) (*LedController, error) {
+	c := &LedController{
+		leds:       leds,
+		opts:       opts,
+		flashUntil: map[int]time.Time{},
+	}
+
+	if opts.NightStart != "" && opts.NightEnd != "" {
+		var err error
+
+		if c.nightStart, err = parseTimeOfDay(opts.NightStart); err != nil {
+			return nil, err
+		}
+
+		if c.nightEnd, err = parseTimeOfDay(opts.NightEnd); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := leds.WakeUp(); err != nil {
+		return nil, err
+	}
+
+	if err := leds.SwitchAll(false); err != nil {
+		return nil, err
+	}
+
+	c.night = c.isNight(time.Now())
+
+	if err := leds.BrightnessAll(c.brightness()); err != nil {
+		return nil, err
+	}
+
+	if err := leds.Switch(LedPower, true); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// parseTimeOfDay parses a 15:04 time into the duration since midnight.
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q (expected HH:MM)", value)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (c *LedController) isNight(now time.Time) bool {
+	if c.nightStart == c.nightEnd {
+		return false
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	since := now.Sub(midnight)
+
+	if c.nightStart < c.nightEnd {
+		return since >= c.nightStart && since < c.nightEnd
+	}
+
+	return since >= c.nightStart || since < c.nightEnd
+}
+
+func (c *LedController) brightness() byte {
+	if c.night {
+		return c.opts.NightBrightness
+	}
+
+	return c.opts.Brightness
+}
+
+// Set switches an LED on or off. A flashing LED stays on until the flash ends.
+func (c *LedController) Set(led int, on bool) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !on && c.flashUntil[led].After(time.Now()) {
+		return
+	}
+
+	c.switchLed(led, on)
+}
+
+// Blink briefly lights an LED.
+func (c *LedController) Blink(led int) {
+	c.Flash(led, ledBlinkDuration)
+}
+
+// Flash lights an LED for the given duration.
+func (c *LedController) Flash(led int, d time.Duration) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	until := time.Now().Add(d)
+	c.flashUntil[led] = until
+	c.switchLed(led, true)
+
+	time.AfterFunc(d, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if c.flashUntil[led].Equal(until) {
+			delete(c.flashUntil, led)
+			c.switchLed(led, false)
+		}
+	})
+}
+
+// Refresh applies the night dimming schedule. It is called periodically by
+// the shutter poller.
+func (c *LedController) Refresh() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	night := c.isNight(time.Now())
+	if night == c.night {
+		return
+	}
+
+	c.night = night
+
+	if err := c.leds.BrightnessAll(c.brightness()); err != nil {
+		log.Printf("Error setting LED brightness: %v", err)
+	}
+}
+
+func (c *LedController) switchLed(led int, on bool) {
+	if err := c.leds.Switch(led, on); err != nil {
+		log.Printf("Error switching LED %d: %v", led, err)
+	}
+}
+
+// ledPinOut mirrors an output pin on its LEDs. Relays light the NO LED when
+// engaged and the NC LED when released; outputs only have a single LED.
+type ledPinOut struct {
+	gpio.PinOut
+	leds *LedController
+	on   int
+	off  int
+}
+
+func (p *ledPinOut) Out(l gpio.Level) error {
+	if err := p.PinOut.Out(l); err != nil {
+		return err
+	}
+
+	p.leds.Set(p.on, bool(l))
+
+	if p.off >= 0 {
+		p.leds.Set(p.off, !bool(l))
+	}
+
+	return nil
+}
+
+// ledPinIn mirrors an input pin on its LED whenever it is read.
+type ledPinIn struct {
+	gpio.PinIn
+	leds *LedController
+	led  int
+}
+
+func (p *ledPinIn) Read() gpio.Level {
+	level := p.PinIn.Read()
+
+	p.leds.Set(p.led, bool(level))
+
+	return level
+}
@@ -0,0 +1,266 @@
+package hardware
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/i2c/i2creg"
+)
+
+// Relay is an output that switches the shutter remote buttons or another
+// device. gpio.PinOut satisfies it.
+type Relay interface {
+	Name() string
+	Out(l gpio.Level) error
+}
+
+// Input is a digital input such as a contact sensor. gpio.PinIn satisfies it.
+type Input interface {
+	Name() string
+	Read() gpio.Level
+}
+
+// Board is the hardware the shutter remote buttons and contact sensors are
+// wired to. Relays and inputs are numbered from 1 in the order returned.
+type Board interface {
+	Relays() []Relay
+	Inputs() []Input
+	Halt() error
+}
+
+// faultReporter is implemented by boards that can lose contact with their
+// inputs, such as networked relays. While Fault returns an error the shutter
+// position is reported as a fault.
+type faultReporter interface {
+	Fault() error
+}
+
+// timedRelay is implemented by relays that can release themselves after a
+// hold time, such as networked relays. A press engages them with the hold time
+// so the relay is not left engaged when the release request is lost.
+type timedRelay interface {
+	Engage(hold time.Duration) error
+}
+
+func getRelay(board Board, relay uint) (Relay, error) {
+	relays := board.Relays()
+	if relay == 0 || relay > uint(len(relays)) {
+		return nil, fmt.Errorf("invalid relay %d", relay)
+	}
+
+	return relays[relay-1], nil
+}
+
+func getInput(board Board, input uint) (Input, error) {
+	inputs := board.Inputs()
+	if input == 0 || input > uint(len(inputs)) {
+		return nil, fmt.Errorf("invalid input %d", input)
+	}
+
+	return inputs[input-1], nil
+}
+
+// openBoard opens the board selected by the options, taking the inputs from
+// the input board when one is set. The Automation HAT is also returned for the
+// features only it provides, and is nil for any other board.
+func openBoard(opts ShutterOptions) (Board, *AutomationHat, error) {
+	board, hat, err := openSingleBoard(opts.Board, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.InputBoard != "" {
+		inputs, inputHat, err := openSingleBoard(opts.InputBoard, opts)
+		if err != nil {
+			board.Halt()
+
+			return nil, nil, err
+		}
+
+		board = &splitBoard{relays: board, inputs: inputs}
+
+		// The Automation HAT features stay available whichever of the two
+		// boards it is.
+		if inputHat != nil {
+			hat = inputHat
+		}
+	}
+
+	if opts.RecordFile != "" {
+		trace, err := os.Create(opts.RecordFile)
+		if err != nil {
+			board.Halt()
+
+			return nil, nil, fmt.Errorf("failed to create trace: %w", err)
+		}
+
+		recorder, err := NewRecordingBoard(board, trace)
+		if err != nil {
+			trace.Close()
+			board.Halt()
+
+			return nil, nil, fmt.Errorf("failed to write trace: %w", err)
+		}
+
+		log.Printf("Hardware trace: file=%s status=recording\n", opts.RecordFile)
+
+		board = recorder
+	}
+
+	return board, hat, nil
+}
+
+func openSingleBoard(name string, opts ShutterOptions) (Board, *AutomationHat, error) {
+	switch name {
+	case "", BoardAutomationHat, BoardAutomationHatMini:
+		hatOpts := AutomationHatDefaultOpts
+		hatOpts.Leds = LedOptions{
+			Brightness:      byte(opts.LedBrightness),
+			NightBrightness: byte(opts.LedNightBrightness),
+			NightStart:      opts.LedNightStart,
+			NightEnd:        opts.LedNightEnd,
+		}
+
+		hat, err := NewAutomationHat(&hatOpts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize AutomationHAT: %w", err)
+		}
+
+		return hat, hat, nil
+	case BoardGPIO:
+		board, err := NewGPIOBoard(GPIOBoardOpts{
+			RelayPins:      opts.GPIORelayPins,
+			InputPins:      opts.GPIOInputPins,
+			RelayActiveLow: opts.GPIORelayActiveLow,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize GPIO board: %w", err)
+		}
+
+		return board, nil, nil
+	case BoardMCP23017, BoardPCF8574:
+		bus, err := i2creg.Open(opts.ExpanderBus)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open I2C bus %q: %w", opts.ExpanderBus, err)
+		}
+
+		board, err := NewExpanderBoard(bus, ExpanderBoardOpts{
+			Chip:           name,
+			Address:        uint16(opts.expanderAddress()),
+			RelayPins:      opts.ExpanderRelayPins,
+			InputPins:      opts.ExpanderInputPins,
+			RelayActiveLow: opts.ExpanderRelayActiveLow,
+		})
+		if err != nil {
+			bus.Close()
+
+			return nil, nil, fmt.Errorf("failed to initialize %s board: %w", name, err)
+		}
+
+		return board, nil, nil
+	case BoardShelly:
+		board, err := NewShellyBoard(ShellyBoardOpts{
+			URL:     opts.ShellyURL,
+			Relays:  opts.shellyRelays(),
+			Inputs:  opts.shellyInputs(),
+			Timeout: opts.shellyTimeout(),
+			Retries: opts.ShellyRetries,
+			Poll:    opts.shellyPoll(),
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize Shelly board: %w", err)
+		}
+
+		return board, nil, nil
+	case BoardMQTT:
+		board, err := NewMQTTBoard(MQTTBoardOpts{
+			Broker:      opts.MQTTBroker,
+			ClientID:    opts.MQTTClientID,
+			Username:    opts.MQTTUsername,
+			Password:    opts.MQTTPassword,
+			RelayTopics: opts.MQTTRelayTopics,
+			InputTopics: opts.MQTTInputTopics,
+			PayloadOn:   opts.MQTTPayloadOn,
+			PayloadOff:  opts.MQTTPayloadOff,
+			Stale:       time.Duration(opts.MQTTStaleSeconds) * time.Second,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize MQTT board: %w", err)
+		}
+
+		return board, nil, nil
+	case BoardSerial:
+		port, err := openSerialPort(opts.SerialDevice, opts.SerialBaud)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open serial port %s: %w", opts.SerialDevice, err)
+		}
+
+		board, err := NewSerialBoard(port, SerialBoardOpts{
+			Protocol: opts.SerialProtocol,
+			Relays:   opts.SerialRelays,
+		})
+		if err != nil {
+			port.Close()
+
+			return nil, nil, fmt.Errorf("failed to initialize serial board: %w", err)
+		}
+
+		return board, nil, nil
+	case BoardSimulator:
+		return NewSimulatorBoard(SimulatorBoardOpts{
+			OpenRelay:  opts.OpenButtonRelay,
+			CloseRelay: opts.CloseButtonRelay,
+			OpenInput:  opts.OpenContactInput,
+			CloseInput: opts.CloseContactInput,
+
+			OpenActiveLow:  opts.OpenContactActiveLow,
+			CloseActiveLow: opts.CloseContactActiveLow,
+
+			Travel: time.Duration(opts.SimulatorTravelMs) * time.Millisecond,
+		}), nil, nil
+	case BoardReplay:
+		board, err := OpenReplayBoard(opts.ReplayFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load trace %s: %w", opts.ReplayFile, err)
+		}
+
+		return board, nil, nil
+	}
+
+	return nil, nil, fmt.Errorf("board %q is not supported", name)
+}
+
+// splitBoard takes the relays from one board and the inputs from another, for
+// relay boards without inputs.
+type splitBoard struct {
+	relays Board
+	inputs Board
+}
+
+func (b *splitBoard) Relays() []Relay {
+	return b.relays.Relays()
+}
+
+func (b *splitBoard) Inputs() []Input {
+	return b.inputs.Inputs()
+}
+
+func (b *splitBoard) Fault() error {
+	for _, board := range []Board{b.relays, b.inputs} {
+		if board, ok := board.(faultReporter); ok {
+			if err := board.Fault(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *splitBoard) Halt() error {
+	return errors.Join(b.relays.Halt(), b.inputs.Halt())
+}
@@ -0,0 +1,177 @@
+package hardware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+const calibrateSampleInterval = 20 * time.Millisecond
+
+type CalibrateOptions struct {
+	// Manual leaves operating the shutter to the user instead of pressing
+	// the open and close relays.
+	Manual bool
+	// Timeout is how long to wait for the shutter to finish moving.
+	Timeout time.Duration
+	// Wait shows a prompt and blocks until the user is ready.
+	Wait func(prompt string)
+	Out  io.Writer
+}
+
+// Calibration is the contact polarity and travel times learnt by Calibrate.
+type Calibration struct {
+	OpenContactActiveLow  bool
+	CloseContactActiveLow bool
+	OpenTravel            time.Duration
+	CloseTravel           time.Duration
+}
+
+// travel describes one movement of the shutter during calibration. Either
+// contact may be nil when it is not fitted.
+type travel struct {
+	direction     string
+	relay         uint
+	leaving       Input
+	leavingLevel  gpio.Level
+	arriving      Input
+	arrivingLevel gpio.Level
+}
+
+// Calibrate learns the level of each contact when it is made and how long
+// the shutter takes to open and close. The contacts are read without applying
+// the configured polarity. Polarity is kept from opts for a contact that is
+// not fitted.
+func (h *Hardware) Calibrate(opts ShutterOptions, c CalibrateOptions) (Calibration, error) {
+	cal := Calibration{
+		OpenContactActiveLow:  opts.OpenContactActiveLow,
+		CloseContactActiveLow: opts.CloseContactActiveLow,
+	}
+
+	c.Wait("Close the shutter fully, then press Enter")
+
+	closedOpen, closedClose := readContact(h.openContact), readContact(h.closeContact)
+	fmt.Fprintf(c.Out, "closed: %s\n", h.formatContacts())
+
+	openTravel, err := h.calibrateTravel(travel{
+		direction:     "open",
+		relay:         opts.OpenButtonRelay,
+		leaving:       h.closeContact,
+		leavingLevel:  closedClose,
+		arriving:      h.openContact,
+		arrivingLevel: closedOpen,
+	}, opts, c)
+	if err != nil {
+		return cal, err
+	}
+
+	openedOpen, openedClose := readContact(h.openContact), readContact(h.closeContact)
+	fmt.Fprintf(c.Out, "open: %s\n", h.formatContacts())
+
+	closeTravel, err := h.calibrateTravel(travel{
+		direction:     "close",
+		relay:         opts.CloseButtonRelay,
+		leaving:       h.openContact,
+		leavingLevel:  openedOpen,
+		arriving:      h.closeContact,
+		arrivingLevel: openedClose,
+	}, opts, c)
+	if err != nil {
+		return cal, err
+	}
+
+	if h.openContact != nil {
+		cal.OpenContactActiveLow = openedOpen == gpio.Low
+	}
+
+	if h.closeContact != nil {
+		cal.CloseContactActiveLow = closedClose == gpio.Low
+	}
+
+	cal.OpenTravel = openTravel
+	cal.CloseTravel = closeTravel
+
+	return cal, nil
+}
+
+// calibrateTravel moves the shutter and returns the time from the leaving
+// contact releasing to the arriving contact being made. Without a leaving
+// contact timing starts when the shutter is operated; without an arriving
+// contact the user tells when the shutter has stopped.
+func (h *Hardware) calibrateTravel(t travel, opts ShutterOptions, c CalibrateOptions) (time.Duration, error) {
+	if c.Manual {
+		c.Wait(fmt.Sprintf("Press Enter, then %s the shutter with its remote", t.direction))
+	} else {
+		c.Wait(fmt.Sprintf("Press Enter to %s the shutter (relay %d)", t.direction, t.relay))
+
+		if err := h.PressRelay(t.relay, opts.SwitchHold()); err != nil {
+			return 0, err
+		}
+	}
+
+	deadline := time.Now().Add(c.Timeout)
+
+	left := time.Now()
+	if t.leaving != nil {
+		var err error
+
+		left, err = waitForChange(t.leaving, t.leavingLevel, deadline)
+		if err != nil {
+			return 0, fmt.Errorf("waiting for contact %s to release: %w", t.leaving.Name(), err)
+		}
+	}
+
+	var arrived time.Time
+	if t.arriving != nil {
+		var err error
+
+		arrived, err = waitForChange(t.arriving, t.arrivingLevel, deadline)
+		if err != nil {
+			return 0, fmt.Errorf("waiting for contact %s to make: %w", t.arriving.Name(), err)
+		}
+	} else {
+		c.Wait(fmt.Sprintf("Press Enter as soon as the shutter has stopped moving (%s)", t.direction))
+
+		arrived = time.Now()
+	}
+
+	duration := arrived.Sub(left).Round(100 * time.Millisecond)
+	fmt.Fprintf(c.Out, "%s: travelled in %s\n", t.direction, duration)
+
+	return duration, nil
+}
+
+func (h *Hardware) formatContacts() string {
+	format := func(pin Input) string {
+		if pin == nil {
+			return "none"
+		}
+
+		return pin.Read().String()
+	}
+
+	return fmt.Sprintf("open contact=%s close contact=%s", format(h.openContact), format(h.closeContact))
+}
+
+func readContact(pin Input) gpio.Level {
+	if pin == nil {
+		return gpio.Low
+	}
+
+	return pin.Read()
+}
+
+func waitForChange(pin Input, level gpio.Level, deadline time.Time) (time.Time, error) {
+	for time.Now().Before(deadline) {
+		if pin.Read() != level {
+			return time.Now(), nil
+		}
+
+		time.Sleep(calibrateSampleInterval)
+	}
+
+	return time.Time{}, errors.New("timed out, check the contact wiring")
+}
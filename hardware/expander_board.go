@@ -0,0 +1,247 @@
+package hardware
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/i2c"
+)
+
+const (
+	ExpanderMCP23017 = "mcp23017"
+	ExpanderPCF8574  = "pcf8574"
+)
+
+// MCP23017 registers with the default IOCON.BANK = 0 layout. Port B
+// registers follow their port A register.
+const (
+	mcp23017IODIR = 0x00
+	mcp23017GPIO  = 0x12
+	mcp23017OLAT  = 0x14
+)
+
+type ExpanderBoardOpts struct {
+	Chip    string
+	Address uint16
+	// RelayPins and InputPins are expander pin indices: 0-15 (GPA0-7 then
+	// GPB0-7) on the MCP23017 and 0-7 on the PCF8574.
+	RelayPins []uint
+	InputPins []uint
+	// RelayActiveLow is as for GPIOBoardOpts.
+	RelayActiveLow bool
+}
+
+// ExpanderBoard is a relay board built on an MCP23017 or PCF8574 I2C IO
+// expander.
+type ExpanderBoard struct {
+	opts ExpanderBoardOpts
+	dev  i2c.Dev
+
+	mu      sync.Mutex
+	latch   [2]byte
+	readErr error
+	relays  []Relay
+	inputs  []Input
+}
+
+// NewExpanderBoard sets up the expander on the bus, releasing all relays.
+func NewExpanderBoard(bus i2c.Bus, opts ExpanderBoardOpts) (*ExpanderBoard, error) {
+	b := &ExpanderBoard{
+		opts: opts,
+		dev:  i2c.Dev{Bus: bus, Addr: opts.Address},
+	}
+
+	pins := 8
+	if opts.Chip == ExpanderMCP23017 {
+		pins = 16
+	} else if opts.Chip != ExpanderPCF8574 {
+		return nil, fmt.Errorf("expander %q is not supported", opts.Chip)
+	}
+
+	// Pins that are not relays are left as inputs. On the PCF8574 an input is
+	// a pin that is written high, so relays that are released need their
+	// latch bit set to the inactive level and inputs need theirs set high.
+	b.latch = [2]byte{0xFF, 0xFF}
+	direction := [2]byte{0xFF, 0xFF}
+
+	for i, pin := range opts.RelayPins {
+		if pin >= uint(pins) {
+			return nil, fmt.Errorf("relay pin %d is not a pin on the %s", pin, opts.Chip)
+		}
+
+		b.setLatch(pin, gpio.Level(opts.RelayActiveLow))
+		direction[pin/8] &^= 1 << (pin % 8)
+
+		b.relays = append(b.relays, &expanderRelay{board: b, pin: pin, name: fmt.Sprintf("%s relay %d", opts.Chip, i+1)})
+	}
+
+	for i, pin := range opts.InputPins {
+		if pin >= uint(pins) {
+			return nil, fmt.Errorf("input pin %d is not a pin on the %s", pin, opts.Chip)
+		}
+
+		b.inputs = append(b.inputs, &expanderInput{board: b, pin: pin, name: fmt.Sprintf("%s input %d", opts.Chip, i+1)})
+	}
+
+	if opts.Chip == ExpanderMCP23017 {
+		// The latch is written before the direction so relays do not click
+		// when their pins become outputs.
+		if err := b.write(append([]byte{mcp23017OLAT}, b.latch[:]...)...); err != nil {
+			return nil, err
+		}
+
+		if err := b.write(append([]byte{mcp23017IODIR}, direction[:]...)...); err != nil {
+			return nil, err
+		}
+	} else if err := b.write(b.latch[0]); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *ExpanderBoard) Relays() []Relay {
+	return b.relays
+}
+
+func (b *ExpanderBoard) Inputs() []Input {
+	return b.inputs
+}
+
+// Fault returns an error while the expander cannot be read. Once a read has
+// failed the bus is read again on each call, so the fault clears as soon as the
+// expander answers.
+func (b *ExpanderBoard) Fault() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.readErr != nil {
+		b.readPort(0)
+	}
+
+	return b.readErr
+}
+
+// Halt releases the relays and closes the bus.
+func (b *ExpanderBoard) Halt() error {
+	for _, relay := range b.relays {
+		if err := relay.Out(gpio.Low); err != nil {
+			return err
+		}
+	}
+
+	if closer, ok := b.dev.Bus.(i2c.BusCloser); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+func (b *ExpanderBoard) setLatch(pin uint, l gpio.Level) {
+	if l {
+		b.latch[pin/8] |= 1 << (pin % 8)
+	} else {
+		b.latch[pin/8] &^= 1 << (pin % 8)
+	}
+}
+
+func (b *ExpanderBoard) write(data ...byte) error {
+	if err := b.dev.Tx(data, nil); err != nil {
+		return fmt.Errorf("writing %s at %#x: %w", b.opts.Chip, b.opts.Address, err)
+	}
+
+	return nil
+}
+
+func (b *ExpanderBoard) out(pin uint, l gpio.Level) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.opts.RelayActiveLow {
+		l = !l
+	}
+
+	b.setLatch(pin, l)
+
+	if b.opts.Chip == ExpanderMCP23017 {
+		return b.write(mcp23017OLAT+byte(pin/8), b.latch[pin/8])
+	}
+
+	return b.write(b.latch[0])
+}
+
+// read returns the level of an input pin, or low when the expander cannot be
+// read. The failure is reported through Fault.
+func (b *ExpanderBoard) read(pin uint) gpio.Level {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	port, err := b.readPort(pin / 8)
+	if err != nil {
+		return gpio.Low
+	}
+
+	return port&(1<<(pin%8)) != 0
+}
+
+// readPort reads an input port and records whether the expander could be
+// read. It is called with b.mu held.
+func (b *ExpanderBoard) readPort(index uint) (byte, error) {
+	port := make([]byte, 1)
+
+	var err error
+	if b.opts.Chip == ExpanderMCP23017 {
+		err = b.dev.Tx([]byte{mcp23017GPIO + byte(index)}, port)
+	} else {
+		err = b.dev.Tx(nil, port)
+	}
+
+	if err != nil {
+		if b.readErr == nil {
+			log.Printf("Expander board: chip=%s address=%#x status=unreachable error=%q\n", b.opts.Chip, b.opts.Address, err)
+		}
+
+		b.readErr = fmt.Errorf("reading %s at %#x: %w", b.opts.Chip, b.opts.Address, err)
+
+		return 0, b.readErr
+	}
+
+	if b.readErr != nil {
+		log.Printf("Expander board: chip=%s address=%#x status=reachable\n", b.opts.Chip, b.opts.Address)
+	}
+
+	b.readErr = nil
+
+	return port[0], nil
+}
+
+type expanderRelay struct {
+	board *ExpanderBoard
+	pin   uint
+	name  string
+}
+
+func (r *expanderRelay) Name() string {
+	return r.name
+}
+
+func (r *expanderRelay) Out(l gpio.Level) error {
+	return r.board.out(r.pin, l)
+}
+
+type expanderInput struct {
+	board *ExpanderBoard
+	pin   uint
+	name  string
+}
+
+func (i *expanderInput) Name() string {
+	return i.name
+}
+
+// Read returns the input level, or low when the expander cannot be read.
+func (i *expanderInput) Read() gpio.Level {
+	return i.board.read(i.pin)
+}
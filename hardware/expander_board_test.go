@@ -0,0 +1,293 @@
+package hardware
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/i2c/i2ctest"
+	"periph.io/x/conn/v3/physic"
+)
+
+func TestExpanderBoardSetup(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ExpanderBoardOpts
+		want []i2ctest.IO
+	}{
+		{
+			name: "mcp23017",
+			opts: ExpanderBoardOpts{Chip: ExpanderMCP23017, Address: 0x20, RelayPins: []uint{0, 9}, InputPins: []uint{3, 12}},
+			want: []i2ctest.IO{
+				{Addr: 0x20, W: []byte{mcp23017OLAT, 0xFE, 0xFD}},
+				{Addr: 0x20, W: []byte{mcp23017IODIR, 0xFE, 0xFD}},
+			},
+		},
+		{
+			name: "mcp23017 active low",
+			opts: ExpanderBoardOpts{Chip: ExpanderMCP23017, Address: 0x21, RelayPins: []uint{0, 9}, RelayActiveLow: true},
+			want: []i2ctest.IO{
+				{Addr: 0x21, W: []byte{mcp23017OLAT, 0xFF, 0xFF}},
+				{Addr: 0x21, W: []byte{mcp23017IODIR, 0xFE, 0xFD}},
+			},
+		},
+		{
+			name: "pcf8574",
+			opts: ExpanderBoardOpts{Chip: ExpanderPCF8574, Address: 0x27, RelayPins: []uint{0, 1}, InputPins: []uint{4}},
+			want: []i2ctest.IO{
+				{Addr: 0x27, W: []byte{0xFC}},
+			},
+		},
+		{
+			name: "pcf8574 active low",
+			opts: ExpanderBoardOpts{Chip: ExpanderPCF8574, Address: 0x27, RelayPins: []uint{0, 1}, InputPins: []uint{4}, RelayActiveLow: true},
+			want: []i2ctest.IO{
+				{Addr: 0x27, W: []byte{0xFF}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &i2ctest.Record{}
+
+			if _, err := NewExpanderBoard(rec, tt.opts); err != nil {
+				t.Fatalf("NewExpanderBoard() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(rec.Ops, tt.want) {
+				t.Errorf("setup ops = %#v, want %#v", rec.Ops, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpanderBoardInvalidPins(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ExpanderBoardOpts
+	}{
+		{"mcp23017 relay", ExpanderBoardOpts{Chip: ExpanderMCP23017, RelayPins: []uint{16}}},
+		{"pcf8574 relay", ExpanderBoardOpts{Chip: ExpanderPCF8574, RelayPins: []uint{8}}},
+		{"pcf8574 input", ExpanderBoardOpts{Chip: ExpanderPCF8574, InputPins: []uint{8}}},
+		{"unknown chip", ExpanderBoardOpts{Chip: "tca9555"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewExpanderBoard(&i2ctest.Record{}, tt.opts); err == nil {
+				t.Error("NewExpanderBoard() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestExpanderBoardMCP23017IO(t *testing.T) {
+	bus := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x20, W: []byte{mcp23017OLAT, 0xFE, 0xFD}},
+			{Addr: 0x20, W: []byte{mcp23017IODIR, 0xFE, 0xFD}},
+			// relay 2 on GPB1, then relay 1 on GPA0
+			{Addr: 0x20, W: []byte{mcp23017OLAT + 1, 0xFF}},
+			{Addr: 0x20, W: []byte{mcp23017OLAT, 0xFF}},
+			// input 1 on GPA3, input 2 on GPB4
+			{Addr: 0x20, W: []byte{mcp23017GPIO}, R: []byte{0x08}},
+			{Addr: 0x20, W: []byte{mcp23017GPIO + 1}, R: []byte{0xEF}},
+			// Halt releases both relays
+			{Addr: 0x20, W: []byte{mcp23017OLAT, 0xFE}},
+			{Addr: 0x20, W: []byte{mcp23017OLAT + 1, 0xFD}},
+		},
+	}
+
+	board, err := NewExpanderBoard(bus, ExpanderBoardOpts{
+		Chip:      ExpanderMCP23017,
+		Address:   0x20,
+		RelayPins: []uint{0, 9},
+		InputPins: []uint{3, 12},
+	})
+	if err != nil {
+		t.Fatalf("NewExpanderBoard() error = %v", err)
+	}
+
+	for _, i := range []int{1, 0} {
+		if err := board.Relays()[i].Out(gpio.High); err != nil {
+			t.Fatalf("relay %d Out() error = %v", i+1, err)
+		}
+	}
+
+	if got := board.Inputs()[0].Read(); got != gpio.High {
+		t.Errorf("input 1 Read() = %s, want High", got)
+	}
+
+	if got := board.Inputs()[1].Read(); got != gpio.Low {
+		t.Errorf("input 2 Read() = %s, want Low", got)
+	}
+
+	if err := board.Halt(); err != nil {
+		t.Errorf("Halt() error = %v", err)
+	}
+}
+
+func TestExpanderBoardPCF8574IO(t *testing.T) {
+	bus := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x27, W: []byte{0xFF}},
+			// relay 1 is active low so it engages when P0 is cleared
+			{Addr: 0x27, W: []byte{0xFE}},
+			{Addr: 0x27, R: []byte{0xEE}},
+			{Addr: 0x27, R: []byte{0xFE}},
+			// Halt releases both relays
+			{Addr: 0x27, W: []byte{0xFF}},
+			{Addr: 0x27, W: []byte{0xFF}},
+		},
+	}
+
+	board, err := NewExpanderBoard(bus, ExpanderBoardOpts{
+		Chip:           ExpanderPCF8574,
+		Address:        0x27,
+		RelayPins:      []uint{0, 1},
+		InputPins:      []uint{4},
+		RelayActiveLow: true,
+	})
+	if err != nil {
+		t.Fatalf("NewExpanderBoard() error = %v", err)
+	}
+
+	if err := board.Relays()[0].Out(gpio.High); err != nil {
+		t.Fatalf("relay 1 Out() error = %v", err)
+	}
+
+	if got := board.Inputs()[0].Read(); got != gpio.Low {
+		t.Errorf("input 1 Read() = %s, want Low", got)
+	}
+
+	if got := board.Inputs()[0].Read(); got != gpio.High {
+		t.Errorf("input 1 Read() = %s, want High", got)
+	}
+
+	if err := board.Halt(); err != nil {
+		t.Errorf("Halt() error = %v", err)
+	}
+}
+
+func TestExpanderBoardFault(t *testing.T) {
+	bus := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x20, W: []byte{0xFF}},
+		},
+		DontPanic: true,
+	}
+
+	board, err := NewExpanderBoard(bus, ExpanderBoardOpts{Chip: ExpanderPCF8574, Address: 0x20, InputPins: []uint{0}})
+	if err != nil {
+		t.Fatalf("NewExpanderBoard() error = %v", err)
+	}
+
+	if err := board.Fault(); err != nil {
+		t.Fatalf("Fault() before a read = %v, want nil", err)
+	}
+
+	// The playback has no more ops so the read fails.
+	if got := board.Inputs()[0].Read(); got != gpio.Low {
+		t.Errorf("Read() on a failed bus = %s, want Low", got)
+	}
+
+	if err := board.Fault(); err == nil {
+		t.Error("Fault() after a failed read = nil, want an error")
+	}
+
+	// Fault reads the bus again, so it clears once the expander answers.
+	bus.Ops = append(bus.Ops, i2ctest.IO{Addr: 0x20, R: []byte{0x01}})
+
+	if err := board.Fault(); err != nil {
+		t.Errorf("Fault() once the bus answers = %v, want nil", err)
+	}
+
+	bus.Ops = append(bus.Ops, i2ctest.IO{Addr: 0x20, R: []byte{0x01}})
+
+	if got := board.Inputs()[0].Read(); got != gpio.High {
+		t.Errorf("Read() = %s, want High", got)
+	}
+
+	if err := board.Fault(); err != nil {
+		t.Errorf("Fault() after a good read = %v, want nil", err)
+	}
+}
+
+// flakyBus is a PCF8574 whose pins read as port until failing is set.
+type flakyBus struct {
+	mu      sync.Mutex
+	port    byte
+	failing bool
+}
+
+func (b *flakyBus) String() string {
+	return "flaky"
+}
+
+func (b *flakyBus) Tx(_ uint16, _, r []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failing {
+		return errors.New("no acknowledge")
+	}
+
+	if len(r) > 0 {
+		r[0] = b.port
+	}
+
+	return nil
+}
+
+func (b *flakyBus) SetSpeed(physic.Frequency) error {
+	return nil
+}
+
+func (b *flakyBus) fail(failing bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failing = failing
+}
+
+func TestShutterPollExpanderFault(t *testing.T) {
+	bus := &flakyBus{port: 0b10}
+
+	board, err := NewExpanderBoard(bus, ExpanderBoardOpts{Chip: ExpanderPCF8574, Address: 0x20, RelayPins: []uint{2, 3}, InputPins: []uint{0, 1}})
+	if err != nil {
+		t.Fatalf("NewExpanderBoard() error = %v", err)
+	}
+
+	s, err := NewShutterWithBoard(board, ShutterOptions{
+		OpenButtonRelay:   1,
+		CloseButtonRelay:  2,
+		OpenContactInput:  1,
+		CloseContactInput: 2,
+	})
+	if err != nil {
+		t.Fatalf("NewShutterWithBoard() error = %v", err)
+	}
+
+	steps := []struct {
+		name    string
+		failing bool
+		want    shutterState
+	}{
+		{"closed", false, shutterStateClosed},
+		{"read fails", true, shutterStateFault},
+		{"still failing", true, shutterStateFault},
+		{"recovered", false, shutterStateClosed},
+	}
+
+	for _, step := range steps {
+		bus.fail(step.failing)
+		s.poll()
+
+		if s.shutterState != step.want {
+			t.Errorf("%s: shutter state = %s, want %s", step.name, s.shutterState, step.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package hardware
+
+import (
+	"fmt"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpioreg"
+)
+
+type GPIOBoardOpts struct {
+	// RelayPins and InputPins are periph pin names such as GPIO13.
+	RelayPins []string
+	InputPins []string
+	// RelayActiveLow is set for relay boards that engage when the pin is
+	// driven low.
+	RelayActiveLow bool
+}
+
+// GPIOBoard is a relay board and contact sensors wired directly to the GPIO
+// pins of any board supported by periph.
+type GPIOBoard struct {
+	pins   []gpio.PinIO
+	relays []Relay
+	inputs []Input
+}
+
+func NewGPIOBoard(opts GPIOBoardOpts) (*GPIOBoard, error) {
+	board := &GPIOBoard{}
+
+	for _, name := range opts.RelayPins {
+		pin := gpioreg.ByName(name)
+		if pin == nil {
+			return nil, fmt.Errorf("unknown relay pin %q", name)
+		}
+
+		var relay Relay = pin
+		if opts.RelayActiveLow {
+			relay = &activeLowRelay{pin}
+		}
+
+		// Make sure the relay starts released.
+		if err := relay.Out(gpio.Low); err != nil {
+			return nil, fmt.Errorf("releasing relay pin %q: %w", name, err)
+		}
+
+		board.pins = append(board.pins, pin)
+		board.relays = append(board.relays, relay)
+	}
+
+	for _, name := range opts.InputPins {
+		pin := gpioreg.ByName(name)
+		if pin == nil {
+			return nil, fmt.Errorf("unknown input pin %q", name)
+		}
+
+		if err := pin.In(gpio.PullNoChange, gpio.NoEdge); err != nil {
+			return nil, fmt.Errorf("setting up input pin %q: %w", name, err)
+		}
+
+		board.pins = append(board.pins, pin)
+		board.inputs = append(board.inputs, pin)
+	}
+
+	return board, nil
+}
+
+func (b *GPIOBoard) Relays() []Relay {
+	return b.relays
+}
+
+func (b *GPIOBoard) Inputs() []Input {
+	return b.inputs
+}
+
+// Halt releases the relays and halts all pins.
+func (b *GPIOBoard) Halt() error {
+	for _, relay := range b.relays {
+		if err := relay.Out(gpio.Low); err != nil {
+			return err
+		}
+	}
+
+	for _, pin := range b.pins {
+		if err := pin.Halt(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// activeLowRelay inverts a pin for relay boards that engage on a low level.
+type activeLowRelay struct {
+	gpio.PinOut
+}
+
+func (r *activeLowRelay) Out(l gpio.Level) error {
+	return r.PinOut.Out(!l)
+}
@@ -0,0 +1,225 @@
+package hardware
+
+import (
+	"fmt"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/host/v3"
+)
+
+// Hardware is the board the shutter remote buttons and contact sensors are
+// wired to. It can be used on its own to operate the shutter without HomeKit.
+type Hardware struct {
+	board        Board
+	hat          *AutomationHat
+	openButton   Relay
+	closeButton  Relay
+	openContact  Input
+	closeContact Input
+
+	// The contacts read low when made rather than high, such as normally
+	// closed reed switches.
+	openActiveLow  bool
+	closeActiveLow bool
+
+	// With a single contact the other end of travel is inferred from the
+	// time since the contact released.
+	openTravel        time.Duration
+	closeTravel       time.Duration
+	contactMade       bool
+	contactReleasedAt time.Time
+
+	motorCurrent *AnalogInput
+	lightSensor  *AnalogInput
+	warningPin   Relay
+}
+
+// NewHardware initializes periph and opens the board described by opts.
+func NewHardware(opts ShutterOptions) (*Hardware, error) {
+	if _, err := host.Init(); err != nil {
+		return nil, fmt.Errorf("failed to initialize periph: %w", err)
+	}
+
+	board, hat, err := openBoard(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return newHardware(board, hat, opts)
+}
+
+// NewHardwareWithBoard sets up the buttons and contacts on a board that is
+// already open, such as a simulator or replay board. The analog inputs and
+// outputs are only available when the board is an Automation HAT.
+func NewHardwareWithBoard(board Board, opts ShutterOptions) (*Hardware, error) {
+	hat, _ := board.(*AutomationHat)
+
+	return newHardware(board, hat, opts)
+}
+
+func newHardware(board Board, hat *AutomationHat, opts ShutterOptions) (*Hardware, error) {
+	openButton, err := getRelay(board, opts.OpenButtonRelay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup open button (relay %d): %w", opts.OpenButtonRelay, err)
+	}
+	closeButton, err := getRelay(board, opts.CloseButtonRelay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup close button (relay %d): %w", opts.CloseButtonRelay, err)
+	}
+
+	openContact, err := setupContact(board, opts.OpenContactInput, opts.OpenContactPull)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup open sensor (input %d): %w", opts.OpenContactInput, err)
+	}
+	closeContact, err := setupContact(board, opts.CloseContactInput, opts.CloseContactPull)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup close sensor (input %d): %w", opts.CloseContactInput, err)
+	}
+
+	if hat == nil && (opts.MotorCurrentADC != 0 || opts.LightSensorADC != 0 || opts.CloseWarningOutput != 0) {
+		return nil, fmt.Errorf("board %s has no analog inputs or outputs", opts.Board)
+	}
+
+	var motorCurrent *AnalogInput
+	if opts.MotorCurrentADC != 0 {
+		motorCurrent, err = hat.GetAnalog(opts.MotorCurrentADC)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup motor current sensor (ADC %d): %w", opts.MotorCurrentADC, err)
+		}
+	}
+
+	var lightSensor *AnalogInput
+	if opts.LightSensorADC != 0 {
+		lightSensor, err = hat.GetAnalog(opts.LightSensorADC)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup light sensor (ADC %d): %w", opts.LightSensorADC, err)
+		}
+	}
+
+	var warningPin Relay
+	switch {
+	case opts.CloseWarningOutput != 0:
+		warningPin, err = hat.GetOutput(opts.CloseWarningOutput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup close warning (output %d): %w", opts.CloseWarningOutput, err)
+		}
+	case opts.CloseWarningRelay != 0:
+		warningPin, err = getRelay(board, opts.CloseWarningRelay)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup close warning (relay %d): %w", opts.CloseWarningRelay, err)
+		}
+	}
+
+	return &Hardware{
+		board:        board,
+		hat:          hat,
+		openButton:   openButton,
+		closeButton:  closeButton,
+		openContact:  openContact,
+		closeContact: closeContact,
+
+		openActiveLow:  opts.OpenContactActiveLow,
+		closeActiveLow: opts.CloseContactActiveLow,
+
+		openTravel:  time.Duration(opts.OpenTravelMs) * time.Millisecond,
+		closeTravel: time.Duration(opts.CloseTravelMs) * time.Millisecond,
+
+		motorCurrent: motorCurrent,
+		lightSensor:  lightSensor,
+		warningPin:   warningPin,
+	}, nil
+}
+
+// setupContact returns the input for a contact sensor, or nil when the contact
+// is not fitted.
+func setupContact(board Board, input uint, pull string) (Input, error) {
+	if input == 0 {
+		return nil, nil
+	}
+
+	contact, err := getInput(board, input)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := parsePull(pull)
+	if err != nil {
+		return nil, err
+	}
+
+	if p != gpio.PullNoChange {
+		pin, ok := contact.(gpio.PinIn)
+		if !ok {
+			return nil, fmt.Errorf("input %s does not support pull resistors", contact.Name())
+		}
+
+		if err := pin.In(p, gpio.NoEdge); err != nil {
+			return nil, err
+		}
+	}
+
+	return contact, nil
+}
+
+// Position returns the shutter position read from the contact sensors.
+func (h *Hardware) Position() string {
+	return h.getShutterPosition().String()
+}
+
+// PressRelay engages a relay for the given duration.
+func (h *Hardware) PressRelay(relay uint, hold time.Duration) error {
+	button, err := getRelay(h.board, relay)
+	if err != nil {
+		return err
+	}
+
+	return h.press(button, hold)
+}
+
+// ReadInputs returns the level of every input on the board, in input order.
+func (h *Hardware) ReadInputs() []gpio.Level {
+	inputs := h.board.Inputs()
+
+	levels := make([]gpio.Level, 0, len(inputs))
+	for _, input := range inputs {
+		levels = append(levels, input.Read())
+	}
+
+	return levels
+}
+
+func (h *Hardware) leds() *LedController {
+	if h.hat == nil {
+		return nil
+	}
+
+	return h.hat.Leds()
+}
+
+func (h *Hardware) Halt() error {
+	return h.board.Halt()
+}
+
+// engage switches a relay on, letting a timed relay release itself after hold.
+func engage(relay Relay, hold time.Duration) error {
+	if timed, ok := relay.(timedRelay); ok {
+		return timed.Engage(hold)
+	}
+
+	return relay.Out(true)
+}
+
+func (h *Hardware) press(button Relay, hold time.Duration) error {
+	if err := engage(button, hold); err != nil {
+		return fmt.Errorf("engaging relay %q: %w", button.Name(), err)
+	}
+
+	time.Sleep(hold)
+
+	if err := button.Out(false); err != nil {
+		return fmt.Errorf("releasing relay %q: %w", button.Name(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package hardware
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"periph.io/x/conn/v3/gpio"
+)
+
+const mqttTimeout = 10 * time.Second
+
+type MQTTBoardOpts struct {
+	// Broker is the broker URL, e.g. tcp://192.168.1.10:1883.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// RelayTopics are the command topics the relay states are published to
+	// and InputTopics the topics the contact states are received on.
+	RelayTopics []string
+	InputTopics []string
+	// PayloadOn and PayloadOff are the relay and contact state payloads. Any
+	// input payload other than PayloadOn reads as low.
+	PayloadOn  string
+	PayloadOff string
+	// Stale is how long an input may go without a message before the board
+	// reports a fault. Zero disables the check.
+	Stale time.Duration
+}
+
+// MQTTBoard is a relay and contact sensors on a remote device, such as an ESP
+// running Tasmota or ESPHome, driven through an MQTT broker.
+type MQTTBoard struct {
+	opts   MQTTBoardOpts
+	client mqtt.Client
+
+	relays []Relay
+	inputs []*mqttInput
+}
+
+// NewMQTTBoard connects to the broker and subscribes to the input topics. The
+// subscriptions are renewed whenever the connection is re-established.
+func NewMQTTBoard(opts MQTTBoardOpts) (*MQTTBoard, error) {
+	b := &MQTTBoard{opts: opts}
+
+	for _, topic := range opts.RelayTopics {
+		b.relays = append(b.relays, &mqttRelay{board: b, topic: topic})
+	}
+
+	for _, topic := range opts.InputTopics {
+		b.inputs = append(b.inputs, &mqttInput{board: b, topic: topic})
+	}
+
+	clientOpts := mqtt.NewClientOptions().
+		AddBroker(opts.Broker).
+		SetClientID(opts.ClientID).
+		SetUsername(opts.Username).
+		SetPassword(opts.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(b.subscribe).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("MQTT board: broker=%s status=disconnected error=%q\n", opts.Broker, err)
+		})
+
+	b.client = mqtt.NewClient(clientOpts)
+
+	// With connect retry enabled the token only completes once connected, so
+	// an unreachable broker is reported as a fault rather than failing here.
+	b.client.Connect()
+
+	return b, nil
+}
+
+func (b *MQTTBoard) subscribe(client mqtt.Client) {
+	log.Printf("MQTT board: broker=%s status=connected\n", b.opts.Broker)
+
+	for _, input := range b.inputs {
+		token := client.Subscribe(input.topic, 1, input.receive)
+		if token.WaitTimeout(mqttTimeout) && token.Error() != nil {
+			log.Printf("MQTT board: topic=%s status=unsubscribed error=%q\n", input.topic, token.Error())
+		}
+	}
+}
+
+func (b *MQTTBoard) Relays() []Relay {
+	return b.relays
+}
+
+func (b *MQTTBoard) Inputs() []Input {
+	inputs := make([]Input, 0, len(b.inputs))
+	for _, input := range b.inputs {
+		inputs = append(inputs, input)
+	}
+
+	return inputs
+}
+
+// Fault returns an error while the broker is unreachable or an input has not
+// received a message recently enough to be trusted.
+func (b *MQTTBoard) Fault() error {
+	if !b.client.IsConnectionOpen() {
+		return fmt.Errorf("not connected to %s", b.opts.Broker)
+	}
+
+	for _, input := range b.inputs {
+		if err := input.fault(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Halt releases the relays and disconnects from the broker.
+func (b *MQTTBoard) Halt() error {
+	var errs []error
+	for _, relay := range b.relays {
+		errs = append(errs, relay.Out(gpio.Low))
+	}
+
+	b.client.Disconnect(uint(time.Second / time.Millisecond))
+
+	return errors.Join(errs...)
+}
+
+type mqttRelay struct {
+	board *MQTTBoard
+	topic string
+}
+
+func (r *mqttRelay) Name() string {
+	return r.topic
+}
+
+func (r *mqttRelay) Out(l gpio.Level) error {
+	payload := r.board.opts.PayloadOff
+	if l {
+		payload = r.board.opts.PayloadOn
+	}
+
+	// Relay commands are only published while connected and at QoS 0, so paho
+	// never queues a press during an outage and sends it once the broker is
+	// back, long after the matching release was given up on.
+	if !r.board.client.IsConnectionOpen() {
+		return fmt.Errorf("publishing to %s: not connected to %s", r.topic, r.board.opts.Broker)
+	}
+
+	token := r.board.client.Publish(r.topic, 0, false, payload)
+	if !token.WaitTimeout(mqttTimeout) {
+		return fmt.Errorf("publishing to %s: timed out", r.topic)
+	}
+
+	return token.Error()
+}
+
+type mqttInput struct {
+	board *MQTTBoard
+	topic string
+
+	mu         sync.Mutex
+	level      gpio.Level
+	receivedAt time.Time
+}
+
+func (i *mqttInput) Name() string {
+	return i.topic
+}
+
+// Read returns the level from the last message received.
+func (i *mqttInput) Read() gpio.Level {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.level
+}
+
+func (i *mqttInput) receive(_ mqtt.Client, msg mqtt.Message) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.level = string(msg.Payload()) == i.board.opts.PayloadOn
+	i.receivedAt = time.Now()
+}
+
+func (i *mqttInput) fault() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	switch {
+	case i.receivedAt.IsZero():
+		return fmt.Errorf("no message on %s", i.topic)
+	case i.board.opts.Stale != 0 && time.Since(i.receivedAt) > i.board.opts.Stale:
+		return fmt.Errorf("no message on %s since %s", i.topic, i.receivedAt.Format(time.TimeOnly))
+	}
+
+	return nil
+}
@@ -0,0 +1,289 @@
+package hardware
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+// mqttMessage is a publish seen by the fake broker.
+type mqttMessage struct {
+	Topic   string
+	Payload string
+	QoS     byte
+}
+
+// fakeBroker is an MQTT 3.1.1 broker that speaks just enough of the protocol
+// for a single paho client: connect, subscribe, publish and ping.
+type fakeBroker struct {
+	listener net.Listener
+
+	mu         sync.Mutex
+	conns      []net.Conn
+	subscribed []string
+	published  []mqttMessage
+}
+
+func newFakeBroker(t *testing.T, addr string) *fakeBroker {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("listening on %s: %v", addr, err)
+	}
+
+	b := &fakeBroker{listener: listener}
+	t.Cleanup(b.close)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			b.mu.Lock()
+			b.conns = append(b.conns, conn)
+			b.mu.Unlock()
+
+			go b.serve(conn)
+		}
+	}()
+
+	return b
+}
+
+func (b *fakeBroker) url() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+// close stops listening and drops every client connection.
+func (b *fakeBroker) close() {
+	b.listener.Close()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+	b.conns = nil
+}
+
+func (b *fakeBroker) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+
+	for {
+		header, body, err := readMQTTPacket(r)
+		if err != nil {
+			conn.Close()
+
+			return
+		}
+
+		switch header >> 4 {
+		case 1: // CONNECT
+			conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+		case 3: // PUBLISH
+			qos := (header >> 1) & 0x03
+			n := int(body[0])<<8 | int(body[1])
+			topic := string(body[2 : 2+n])
+			payload := body[2+n:]
+
+			if qos > 0 {
+				conn.Write([]byte{0x40, 0x02, payload[0], payload[1]})
+				payload = payload[2:]
+			}
+
+			b.mu.Lock()
+			b.published = append(b.published, mqttMessage{topic, string(payload), qos})
+			b.mu.Unlock()
+		case 8: // SUBSCRIBE
+			suback := []byte{0x90, 0x02, body[0], body[1]}
+
+			b.mu.Lock()
+			for rest := body[2:]; len(rest) > 2; {
+				n := int(rest[0])<<8 | int(rest[1])
+				b.subscribed = append(b.subscribed, string(rest[2:2+n]))
+				suback = append(suback, rest[2+n])
+				rest = rest[3+n:]
+			}
+			b.mu.Unlock()
+
+			suback[1] = byte(len(suback) - 2)
+			conn.Write(suback)
+		case 12: // PINGREQ
+			conn.Write([]byte{0xD0, 0x00})
+		case 14: // DISCONNECT
+			conn.Close()
+
+			return
+		}
+	}
+}
+
+// send publishes a message at QoS 0 to every connected client.
+func (b *fakeBroker) send(topic, payload string) {
+	body := append([]byte{byte(len(topic) >> 8), byte(len(topic))}, topic...)
+	body = append(body, payload...)
+	packet := append([]byte{0x30, byte(len(body))}, body...)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, conn := range b.conns {
+		conn.Write(packet)
+	}
+}
+
+func (b *fakeBroker) subscriptions() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]string(nil), b.subscribed...)
+}
+
+func (b *fakeBroker) messages() []mqttMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]mqttMessage(nil), b.published...)
+}
+
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length := 0
+	for shift := 0; ; shift += 7 {
+		if shift > 21 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		length |= int(c&0x7F) << shift
+		if c&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+
+	return header, body, err
+}
+
+func newTestMQTTBoard(t *testing.T, broker *fakeBroker, opts MQTTBoardOpts) *MQTTBoard {
+	t.Helper()
+
+	opts.Broker = broker.url()
+	opts.ClientID = t.Name()
+	opts.PayloadOn = "ON"
+	opts.PayloadOff = "OFF"
+
+	b, err := NewMQTTBoard(opts)
+	if err != nil {
+		t.Fatalf("NewMQTTBoard() error = %v", err)
+	}
+	t.Cleanup(func() { b.client.Disconnect(0) })
+
+	waitFor(t, "the broker connection", b.client.IsConnectionOpen)
+
+	return b
+}
+
+func TestMQTTBoardRelayPayloads(t *testing.T) {
+	broker := newFakeBroker(t, "127.0.0.1:0")
+	b := newTestMQTTBoard(t, broker, MQTTBoardOpts{RelayTopics: []string{"garage/relay1", "garage/relay2"}})
+
+	if err := b.Relays()[1].Out(gpio.High); err != nil {
+		t.Fatalf("Out(High) error = %v", err)
+	}
+
+	if err := b.Relays()[1].Out(gpio.Low); err != nil {
+		t.Fatalf("Out(Low) error = %v", err)
+	}
+
+	want := []mqttMessage{{"garage/relay2", "ON", 0}, {"garage/relay2", "OFF", 0}}
+
+	waitFor(t, "the relay messages", func() bool { return len(broker.messages()) >= len(want) })
+
+	if got := broker.messages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("published = %+v, want %+v", got, want)
+	}
+}
+
+func TestMQTTBoardInputFault(t *testing.T) {
+	broker := newFakeBroker(t, "127.0.0.1:0")
+	b := newTestMQTTBoard(t, broker, MQTTBoardOpts{
+		InputTopics: []string{"garage/open"},
+		Stale:       100 * time.Millisecond,
+	})
+
+	waitFor(t, "the input subscription", func() bool { return len(broker.subscriptions()) == 1 })
+
+	if err := b.Fault(); err == nil {
+		t.Error("Fault() before any message = nil, want an error")
+	}
+
+	broker.send("garage/open", "ON")
+	waitFor(t, "the input message", func() bool { return b.Fault() == nil })
+
+	if got := b.Inputs()[0].Read(); got != gpio.High {
+		t.Errorf("Read() = %s, want High", got)
+	}
+
+	waitFor(t, "the input to go stale", func() bool { return b.Fault() != nil })
+
+	broker.send("garage/open", "OFF")
+	waitFor(t, "the next input message", func() bool { return b.Fault() == nil })
+
+	if got := b.Inputs()[0].Read(); got != gpio.Low {
+		t.Errorf("Read() = %s, want Low", got)
+	}
+}
+
+func TestMQTTBoardDisconnected(t *testing.T) {
+	broker := newFakeBroker(t, "127.0.0.1:0")
+	b := newTestMQTTBoard(t, broker, MQTTBoardOpts{RelayTopics: []string{"garage/relay1"}})
+
+	addr := broker.listener.Addr().String()
+	broker.close()
+
+	waitFor(t, "the disconnect", func() bool { return b.Fault() != nil })
+
+	if err := b.Relays()[0].Out(gpio.High); err == nil {
+		t.Fatal("Out(High) while disconnected error = nil, want an error")
+	}
+
+	// The press must not be queued and sent once the broker is back.
+	broker = newFakeBroker(t, addr)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !b.client.IsConnectionOpen() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the reconnect")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if got := broker.messages(); len(got) != 0 {
+		t.Errorf("published after reconnecting = %+v, want nothing", got)
+	}
+}
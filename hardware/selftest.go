@@ -0,0 +1,298 @@
+package hardware
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+const selfTestSampleInterval = 50 * time.Millisecond
+
+type SelfTestOptions struct {
+	// DryRun reports which relays would be pressed without pressing them.
+	DryRun bool
+	// Observe is how long the inputs are watched after each relay press and
+	// while the shutter is operated by hand.
+	Observe time.Duration
+	// Confirm is asked before each relay is pressed and before the inputs are
+	// watched. Returning false skips the step.
+	Confirm func(prompt string) bool
+	Out     io.Writer
+}
+
+type inputChange struct {
+	input uint
+	from  gpio.Level
+	to    gpio.Level
+	after time.Duration
+}
+
+type inputWatch struct {
+	changes      []inputChange
+	final        []gpio.Level
+	bothAsserted bool
+	fault        error
+}
+
+// SelfTest walks through every relay and input on the board and writes a
+// report of the observed behaviour and how it relates to the configured
+// relays and inputs.
+func (h *Hardware) SelfTest(opts ShutterOptions, st SelfTestOptions) error {
+	out := st.Out
+	roles := relayRoles(opts)
+
+	fmt.Fprintf(out, "Initial inputs: %s\n\n", FormatLevels(h.ReadInputs()))
+
+	relayWatches := map[uint]*inputWatch{}
+
+	for i := range h.board.Relays() {
+		relay := uint(i + 1)
+
+		if !st.Confirm(fmt.Sprintf("Press relay %d%s?", relay, roles[relay])) {
+			continue
+		}
+
+		if st.DryRun {
+			fmt.Fprintf(out, "relay %d: dry run, not pressed\n", relay)
+
+			continue
+		}
+
+		if err := h.PressRelay(relay, opts.SwitchHold()); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "relay %d: pressed, watching inputs for %s\n", relay, st.Observe)
+
+		relayWatches[relay] = h.watchInputs(opts, st.Observe)
+	}
+
+	var manual *inputWatch
+	if st.Confirm(fmt.Sprintf("Watch the inputs for %s while the shutter is operated with its remote?", st.Observe)) {
+		fmt.Fprintln(out, "Operate the shutter now")
+
+		manual = h.watchInputs(opts, st.Observe)
+	}
+
+	h.writeSelfTestReport(out, opts, relayWatches, manual)
+
+	return nil
+}
+
+func (h *Hardware) watchInputs(opts ShutterOptions, d time.Duration) *inputWatch {
+	watch := &inputWatch{}
+
+	start := time.Now()
+	last := h.ReadInputs()
+
+	for time.Since(start) < d {
+		time.Sleep(selfTestSampleInterval)
+
+		levels := h.ReadInputs()
+		for i, level := range levels {
+			if level != last[i] {
+				watch.changes = append(watch.changes, inputChange{
+					input: uint(i + 1),
+					from:  last[i],
+					to:    level,
+					after: time.Since(start).Round(100 * time.Millisecond),
+				})
+			}
+		}
+
+		if contactsAsserted(opts, levels) {
+			watch.bothAsserted = true
+		}
+
+		if board, ok := h.board.(faultReporter); ok && watch.fault == nil {
+			watch.fault = board.Fault()
+		}
+
+		last = levels
+	}
+
+	watch.final = last
+
+	return watch
+}
+
+func (h *Hardware) writeSelfTestReport(out io.Writer, opts ShutterOptions, relays map[uint]*inputWatch, manual *inputWatch) {
+	fmt.Fprintln(out, "\nReport")
+
+	changed := map[uint]int{}
+	bothAsserted := false
+
+	var fault error
+
+	for i := range h.board.Relays() {
+		relay := uint(i + 1)
+		roles := relayRoles(opts)[relay]
+
+		watch, ok := relays[relay]
+		if !ok {
+			fmt.Fprintf(out, "  relay %d%s: not tested\n", relay, roles)
+
+			continue
+		}
+
+		for _, change := range watch.changes {
+			changed[change.input]++
+		}
+
+		bothAsserted = bothAsserted || watch.bothAsserted
+
+		if fault == nil {
+			fault = watch.fault
+		}
+
+		if len(watch.changes) == 0 {
+			if roles != "" {
+				fmt.Fprintf(out, "  relay %d%s: no input changed, check the relay is wired to the remote\n", relay, roles)
+			} else {
+				fmt.Fprintf(out, "  relay %d: no input changed\n", relay)
+			}
+
+			continue
+		}
+
+		fmt.Fprintf(out, "  relay %d%s: %s\n", relay, roles, formatChanges(watch.changes))
+
+		if input, ok := lastAsserted(opts, watch); ok {
+			switch relay {
+			case opts.OpenButtonRelay:
+				if input != opts.OpenContactInput {
+					fmt.Fprintf(out, "    input %d asserted last, set OpenContactInput = %d\n", input, input)
+				}
+			case opts.CloseButtonRelay:
+				if input != opts.CloseContactInput {
+					fmt.Fprintf(out, "    input %d asserted last, set CloseContactInput = %d\n", input, input)
+				}
+			}
+		}
+	}
+
+	if manual != nil {
+		for _, change := range manual.changes {
+			changed[change.input]++
+		}
+
+		bothAsserted = bothAsserted || manual.bothAsserted
+
+		if fault == nil {
+			fault = manual.fault
+		}
+
+		fmt.Fprintf(out, "  remote: %s\n", formatChanges(manual.changes))
+	}
+
+	for i := range h.board.Inputs() {
+		input := uint(i + 1)
+
+		role := ""
+		switch input {
+		case opts.OpenContactInput:
+			role = " (OpenContactInput)"
+		case opts.CloseContactInput:
+			role = " (CloseContactInput)"
+		}
+
+		if changed[input] == 0 {
+			if role != "" {
+				fmt.Fprintf(out, "  input %d%s: never changed, check the contact wiring\n", input, role)
+			}
+
+			continue
+		}
+
+		fmt.Fprintf(out, "  input %d%s: changed %d times\n", input, role, changed[input])
+	}
+
+	if bothAsserted {
+		fmt.Fprintf(out, "  FAULT: inputs %d and %d were asserted at the same time, check the contacts are not swapped or shorted\n",
+			opts.OpenContactInput, opts.CloseContactInput)
+	}
+
+	if fault != nil {
+		fmt.Fprintf(out, "  FAULT: the board reported %q while the inputs were watched, the input levels may not be real\n", fault)
+	}
+}
+
+func relayRoles(opts ShutterOptions) map[uint]string {
+	roles := map[uint][]string{}
+	roles[opts.OpenButtonRelay] = append(roles[opts.OpenButtonRelay], "OpenButtonRelay")
+	roles[opts.CloseButtonRelay] = append(roles[opts.CloseButtonRelay], "CloseButtonRelay")
+
+	names := map[uint]string{}
+	for relay, role := range roles {
+		names[relay] = " (" + strings.Join(role, ", ") + ")"
+	}
+
+	return names
+}
+
+// lastAsserted returns the input that was asserted last while watching, which
+// is the end of travel contact for the direction the shutter moved.
+func lastAsserted(opts ShutterOptions, watch *inputWatch) (uint, bool) {
+	for i := len(watch.changes) - 1; i >= 0; i-- {
+		change := watch.changes[i]
+		asserted := assertedLevel(opts, change.input)
+
+		if change.to == asserted && watch.final[change.input-1] == asserted {
+			return change.input, true
+		}
+	}
+
+	return 0, false
+}
+
+// contactsAsserted reports whether the open and close contacts both read as
+// made.
+func contactsAsserted(opts ShutterOptions, levels []gpio.Level) bool {
+	open, closed := opts.OpenContactInput, opts.CloseContactInput
+	if open == 0 || closed == 0 || open > uint(len(levels)) || closed > uint(len(levels)) {
+		return false
+	}
+
+	return levels[open-1] == assertedLevel(opts, open) && levels[closed-1] == assertedLevel(opts, closed)
+}
+
+// assertedLevel returns the level an input reads when its contact is made,
+// using the configured polarity for the contact inputs. Inputs that are not
+// configured as a contact are taken to be active high.
+func assertedLevel(opts ShutterOptions, input uint) gpio.Level {
+	switch {
+	case input == opts.OpenContactInput && opts.OpenContactActiveLow:
+		return gpio.Low
+	case input == opts.CloseContactInput && opts.CloseContactActiveLow:
+		return gpio.Low
+	}
+
+	return gpio.High
+}
+
+func formatChanges(changes []inputChange) string {
+	if len(changes) == 0 {
+		return "no input changed"
+	}
+
+	parts := make([]string, 0, len(changes))
+	for _, change := range changes {
+		parts = append(parts, fmt.Sprintf("input %d %s->%s after %s",
+			change.input, strings.ToLower(change.from.String()), strings.ToLower(change.to.String()), change.after))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// FormatLevels formats input levels as input1=high input2=low ...
+func FormatLevels(levels []gpio.Level) string {
+	parts := make([]string, 0, len(levels))
+	for i, level := range levels {
+		parts = append(parts, fmt.Sprintf("input%d=%s", i+1, strings.ToLower(level.String())))
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,55 @@
+package hardware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchInputs(t *testing.T) {
+	opts := ShutterOptions{
+		OpenButtonRelay:      1,
+		CloseButtonRelay:     2,
+		OpenContactInput:     1,
+		CloseContactInput:    2,
+		OpenContactActiveLow: true,
+	}
+
+	tests := []struct {
+		name         string
+		port         byte
+		failing      bool
+		bothAsserted bool
+		fault        bool
+	}{
+		{"closed", 0b11, false, false, false},
+		{"both asserted", 0b10, false, true, false},
+		{"board fault", 0b00, true, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := &flakyBus{port: tt.port, failing: tt.failing}
+
+			board, err := NewExpanderBoard(&flakyBus{}, ExpanderBoardOpts{Chip: ExpanderPCF8574, Address: 0x20, RelayPins: []uint{2, 3}, InputPins: []uint{0, 1}})
+			if err != nil {
+				t.Fatalf("NewExpanderBoard() error = %v", err)
+			}
+			board.dev.Bus = bus
+
+			hw, err := NewHardwareWithBoard(board, opts)
+			if err != nil {
+				t.Fatalf("NewHardwareWithBoard() error = %v", err)
+			}
+
+			watch := hw.watchInputs(opts, 2*selfTestSampleInterval+10*time.Millisecond)
+
+			if watch.bothAsserted != tt.bothAsserted {
+				t.Errorf("bothAsserted = %t, want %t", watch.bothAsserted, tt.bothAsserted)
+			}
+
+			if (watch.fault != nil) != tt.fault {
+				t.Errorf("fault = %v, want a fault %t", watch.fault, tt.fault)
+			}
+		})
+	}
+}
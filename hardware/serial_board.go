@@ -0,0 +1,136 @@
+package hardware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+const (
+	// SerialProtocolLCUS is used by the LCUS USB relay modules: A0, the relay
+	// number, the state and a checksum of the first three bytes.
+	SerialProtocolLCUS = "lcus"
+	// SerialProtocolFF is used by simpler CH340 relay modules: FF, the relay
+	// number and the state.
+	SerialProtocolFF = "ff"
+)
+
+// serialProtocols build the command that switches a relay, numbered from 1.
+var serialProtocols = map[string]func(relay uint, l gpio.Level) []byte{
+	SerialProtocolLCUS: func(relay uint, l gpio.Level) []byte {
+		state := byte(0)
+		if l {
+			state = 1
+		}
+
+		return []byte{0xA0, byte(relay), state, 0xA0 + byte(relay) + state}
+	},
+	SerialProtocolFF: func(relay uint, l gpio.Level) []byte {
+		state := byte(0)
+		if l {
+			state = 1
+		}
+
+		return []byte{0xFF, byte(relay), state}
+	},
+}
+
+func serialProtocolNames() string {
+	names := make([]string, 0, len(serialProtocols))
+	for name := range serialProtocols {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+type SerialBoardOpts struct {
+	Protocol string
+	Relays   uint
+}
+
+// SerialBoard is a USB relay module driven over a serial port. The modules
+// have no inputs, so the contacts need to be wired to an input board.
+type SerialBoard struct {
+	port    io.WriteCloser
+	command func(relay uint, l gpio.Level) []byte
+
+	mu     sync.Mutex
+	relays []Relay
+}
+
+// NewSerialBoard drives the relays through port, releasing them all.
+func NewSerialBoard(port io.WriteCloser, opts SerialBoardOpts) (*SerialBoard, error) {
+	command, ok := serialProtocols[opts.Protocol]
+	if !ok {
+		return nil, fmt.Errorf("protocol %q is not supported (expected one of: %s)", opts.Protocol, serialProtocolNames())
+	}
+
+	b := &SerialBoard{
+		port:    port,
+		command: command,
+	}
+
+	for relay := uint(1); relay <= opts.Relays; relay++ {
+		b.relays = append(b.relays, &serialRelay{board: b, relay: relay})
+	}
+
+	for _, relay := range b.relays {
+		if err := relay.Out(gpio.Low); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+func (b *SerialBoard) Relays() []Relay {
+	return b.relays
+}
+
+func (b *SerialBoard) Inputs() []Input {
+	return nil
+}
+
+// Halt releases the relays and closes the port.
+func (b *SerialBoard) Halt() error {
+	var errs []error
+	for _, relay := range b.relays {
+		errs = append(errs, relay.Out(gpio.Low))
+	}
+
+	errs = append(errs, b.port.Close())
+
+	return errors.Join(errs...)
+}
+
+func (b *SerialBoard) out(relay uint, l gpio.Level) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.port.Write(b.command(relay, l)); err != nil {
+		return fmt.Errorf("switching serial relay %d: %w", relay, err)
+	}
+
+	return nil
+}
+
+type serialRelay struct {
+	board *SerialBoard
+	relay uint
+}
+
+func (r *serialRelay) Name() string {
+	return fmt.Sprintf("serial relay %d", r.relay)
+}
+
+func (r *serialRelay) Out(l gpio.Level) error {
+	return r.board.out(r.relay, l)
+}
@@ -0,0 +1,66 @@
+//go:build linux
+
+package hardware
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+var serialBauds = map[uint]uint32{
+	1200:   unix.B1200,
+	2400:   unix.B2400,
+	4800:   unix.B4800,
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+}
+
+// openSerialPort opens a serial device in raw 8N1 mode at the given baud rate.
+// serialBaudSupported reports whether a serial port can be opened at baud.
+func serialBaudSupported(baud uint) bool {
+	_, ok := serialBauds[baud]
+
+	return ok
+}
+
+func openSerialPort(device string, baud uint) (*os.File, error) {
+	speed, ok := serialBauds[baud]
+	if !ok {
+		return nil, fmt.Errorf("baud rate %d is not supported", baud)
+	}
+
+	port, err := os.OpenFile(device, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fd := int(port.Fd())
+
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		port.Close()
+
+		return nil, fmt.Errorf("%s is not a serial port: %w", device, err)
+	}
+
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	termios.Oflag &^= unix.OPOST
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	termios.Cflag &^= unix.CSIZE | unix.PARENB | unix.CSTOPB | unix.CBAUD
+	termios.Cflag |= unix.CS8 | unix.CREAD | unix.CLOCAL | speed
+	termios.Ispeed = speed
+	termios.Ospeed = speed
+
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, termios); err != nil {
+		port.Close()
+
+		return nil, fmt.Errorf("configuring %s: %w", device, err)
+	}
+
+	return port, nil
+}
@@ -0,0 +1,133 @@
+//go:build linux
+
+package hardware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPty returns the controlling side of a new pseudo terminal and the path
+// of its serial side.
+func openPty(t *testing.T) (*os.File, string) {
+	t.Helper()
+
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("pseudo terminals are not available: %v", err)
+	}
+	t.Cleanup(func() { ptmx.Close() })
+
+	fd := int(ptmx.Fd())
+
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatalf("unlocking pty: %v", err)
+	}
+
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		t.Fatalf("getting pty number: %v", err)
+	}
+
+	return ptmx, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+// readFrames reads n bytes written to the serial side of the pty.
+func readFrames(t *testing.T, ptmx *os.File, n int) []byte {
+	t.Helper()
+
+	got := make([]byte, n)
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := io.ReadFull(ptmx, got)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("reading pty: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %d bytes", n)
+	}
+
+	return got
+}
+
+func TestSerialBoardFrames(t *testing.T) {
+	tests := []struct {
+		protocol string
+		release  [][]byte
+		press    [][]byte
+	}{
+		{
+			protocol: SerialProtocolLCUS,
+			release:  [][]byte{{0xA0, 0x01, 0x00, 0xA1}, {0xA0, 0x02, 0x00, 0xA2}},
+			press:    [][]byte{{0xA0, 0x02, 0x01, 0xA3}, {0xA0, 0x02, 0x00, 0xA2}},
+		},
+		{
+			protocol: SerialProtocolFF,
+			release:  [][]byte{{0xFF, 0x01, 0x00}, {0xFF, 0x02, 0x00}},
+			press:    [][]byte{{0xFF, 0x02, 0x01}, {0xFF, 0x02, 0x00}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.protocol, func(t *testing.T) {
+			ptmx, device := openPty(t)
+
+			port, err := openSerialPort(device, 9600)
+			if err != nil {
+				t.Fatalf("openSerialPort() error = %v", err)
+			}
+
+			board, err := NewSerialBoard(port, SerialBoardOpts{Protocol: tt.protocol, Relays: 2})
+			if err != nil {
+				port.Close()
+				t.Fatalf("NewSerialBoard() error = %v", err)
+			}
+			defer board.Halt()
+
+			want := bytes.Join(tt.release, nil)
+			if got := readFrames(t, ptmx, len(want)); !bytes.Equal(got, want) {
+				t.Errorf("release frames = % X, want % X", got, want)
+			}
+
+			if err := (&Hardware{}).press(board.Relays()[1], 10*time.Millisecond); err != nil {
+				t.Fatalf("press() error = %v", err)
+			}
+
+			want = bytes.Join(tt.press, nil)
+			if got := readFrames(t, ptmx, len(want)); !bytes.Equal(got, want) {
+				t.Errorf("press frames = % X, want % X", got, want)
+			}
+		})
+	}
+}
+
+func TestOpenSerialPortErrors(t *testing.T) {
+	if _, err := openSerialPort("/dev/null", 9600); err == nil {
+		t.Error("openSerialPort(/dev/null) error = nil, want not a serial port")
+	}
+
+	if _, err := openSerialPort("/dev/null", 1234); err == nil {
+		t.Error("openSerialPort() with baud 1234 error = nil, want unsupported")
+	}
+}
+
+func TestValidateSerialBaud(t *testing.T) {
+	opts := ShutterOptions{Board: BoardSerial, SerialDevice: "/dev/ttyUSB0", SerialBaud: 1234}
+
+	if err := opts.Validate(); err == nil || !strings.Contains(err.Error(), "SerialBaud 1234") {
+		t.Errorf("Validate() error = %v, want SerialBaud 1234 not supported", err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package hardware
+
+import (
+	"errors"
+	"os"
+)
+
+// serialBaudSupported accepts any rate, as openSerialPort reports serial boards
+// as unsupported here.
+func serialBaudSupported(baud uint) bool {
+	return true
+}
+
+func openSerialPort(device string, baud uint) (*os.File, error) {
+	return nil, errors.New("serial relay boards are only supported on Linux")
+}
@@ -0,0 +1,259 @@
+package hardware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+type ShellyBoardOpts struct {
+	// URL is the base URL of the device, e.g. http://192.168.1.50.
+	URL    string
+	Relays uint
+	Inputs uint
+	// Timeout applies to each request and Retries is how many times a failed
+	// relay request is repeated.
+	Timeout time.Duration
+	Retries uint
+	// Poll is how often the inputs are read. The board reports a fault when
+	// the device has not answered for three poll intervals.
+	Poll time.Duration
+}
+
+// ShellyBoard is a networked relay with inputs driven through the Shelly
+// Gen1 local HTTP API.
+type ShellyBoard struct {
+	opts   ShellyBoardOpts
+	client *http.Client
+
+	relays []Relay
+	inputs []Input
+
+	mu       sync.Mutex
+	levels   []gpio.Level
+	polledAt time.Time
+	pollErr  error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type shellyStatus struct {
+	Inputs []struct {
+		Input int `json:"input"`
+	} `json:"inputs"`
+}
+
+// NewShellyBoard releases the relays and starts polling the inputs of the
+// device. The device being unreachable is reported as a fault rather than an
+// error so the shutter starts once it comes back.
+func NewShellyBoard(opts ShellyBoardOpts) (*ShellyBoard, error) {
+	if _, err := url.ParseRequestURI(opts.URL); err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", opts.URL, err)
+	}
+
+	b := &ShellyBoard{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		levels: make([]gpio.Level, opts.Inputs),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	for i := range opts.Relays {
+		b.relays = append(b.relays, &shellyRelay{board: b, index: i})
+	}
+
+	for i := range opts.Inputs {
+		b.inputs = append(b.inputs, &shellyInput{board: b, index: i})
+	}
+
+	for _, relay := range b.relays {
+		if err := relay.Out(gpio.Low); err != nil {
+			log.Printf("Shelly board: url=%s status=unreachable error=%q\n", opts.URL, err)
+
+			break
+		}
+	}
+
+	b.poll()
+
+	go b.pollLoop()
+
+	return b, nil
+}
+
+func (b *ShellyBoard) Relays() []Relay {
+	return b.relays
+}
+
+func (b *ShellyBoard) Inputs() []Input {
+	return b.inputs
+}
+
+// Fault returns an error while the input levels are stale.
+func (b *ShellyBoard) Fault() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if time.Since(b.polledAt) <= 3*b.opts.Poll {
+		return nil
+	}
+
+	if b.pollErr != nil {
+		return fmt.Errorf("no status from %s: %w", b.opts.URL, b.pollErr)
+	}
+
+	return fmt.Errorf("no status from %s", b.opts.URL)
+}
+
+// Halt stops polling and releases the relays.
+func (b *ShellyBoard) Halt() error {
+	close(b.stop)
+	<-b.done
+
+	var errs []error
+	for _, relay := range b.relays {
+		errs = append(errs, relay.Out(gpio.Low))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (b *ShellyBoard) pollLoop() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.opts.Poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.poll()
+		}
+	}
+}
+
+func (b *ShellyBoard) poll() {
+	var status shellyStatus
+
+	err := b.get("/status", &status)
+	if err == nil && len(status.Inputs) < len(b.levels) {
+		err = fmt.Errorf("device has %d inputs, %d configured", len(status.Inputs), len(b.levels))
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err != nil {
+		if b.pollErr == nil {
+			log.Printf("Shelly board: url=%s status=unreachable error=%q\n", b.opts.URL, err)
+		}
+
+		b.pollErr = err
+
+		return
+	}
+
+	if b.pollErr != nil {
+		log.Printf("Shelly board: url=%s status=reachable\n", b.opts.URL)
+	}
+
+	for i := range b.levels {
+		b.levels[i] = status.Inputs[i].Input != 0
+	}
+
+	b.polledAt = time.Now()
+	b.pollErr = nil
+}
+
+// get requests a path from the device and decodes the JSON response into v.
+func (b *ShellyBoard) get(path string, v any) error {
+	resp, err := b.client.Get(strings.TrimSuffix(b.opts.URL, "/") + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// setRelay switches a relay, retrying failed requests. A relay turned on with
+// a hold time is switched off again by the device itself once the hold has
+// passed; the timer is in whole seconds so it is rounded up.
+func (b *ShellyBoard) setRelay(index uint, l gpio.Level, hold time.Duration) error {
+	turn := "off"
+	if l {
+		turn = "on"
+	}
+
+	path := fmt.Sprintf("/relay/%d?turn=%s", index, turn)
+	if l && hold > 0 {
+		path += fmt.Sprintf("&timer=%d", int(math.Ceil(hold.Seconds())))
+	}
+
+	var err error
+	for attempt := uint(0); attempt <= b.opts.Retries; attempt++ {
+		var state struct {
+			IsOn bool `json:"ison"`
+		}
+
+		if err = b.get(path, &state); err == nil {
+			return nil
+		}
+
+		log.Printf("Shelly board: relay=%d turn=%s attempt=%d error=%q\n", index, turn, attempt+1, err)
+	}
+
+	return err
+}
+
+type shellyRelay struct {
+	board *ShellyBoard
+	index uint
+}
+
+func (r *shellyRelay) Name() string {
+	return fmt.Sprintf("shelly relay %d", r.index+1)
+}
+
+func (r *shellyRelay) Out(l gpio.Level) error {
+	return r.board.setRelay(r.index, l, 0)
+}
+
+// Engage turns the relay on with the device timer set to release it after hold.
+func (r *shellyRelay) Engage(hold time.Duration) error {
+	return r.board.setRelay(r.index, true, hold)
+}
+
+type shellyInput struct {
+	board *ShellyBoard
+	index uint
+}
+
+func (i *shellyInput) Name() string {
+	return fmt.Sprintf("shelly input %d", i.index+1)
+}
+
+// Read returns the input level from the last successful poll.
+func (i *shellyInput) Read() gpio.Level {
+	i.board.mu.Lock()
+	defer i.board.mu.Unlock()
+
+	return i.board.levels[i.index]
+}
@@ -0,0 +1,213 @@
+package hardware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+// fakeShelly is a Shelly Gen1 device serving /relay and /status.
+type fakeShelly struct {
+	mu           sync.Mutex
+	inputs       []int
+	relayFailing int
+	statusDown   bool
+	requests     []string
+}
+
+func (f *fakeShelly) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/status":
+		if f.statusDown {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+
+			return
+		}
+
+		fmt.Fprint(w, `{"inputs":[`)
+		for i, input := range f.inputs {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+
+			fmt.Fprintf(w, `{"input":%d}`, input)
+		}
+		fmt.Fprint(w, `]}`)
+	default:
+		f.requests = append(f.requests, r.URL.RequestURI())
+
+		if f.relayFailing > 0 {
+			f.relayFailing--
+			http.Error(w, "busy", http.StatusInternalServerError)
+
+			return
+		}
+
+		fmt.Fprint(w, `{"ison":true}`)
+	}
+}
+
+func (f *fakeShelly) relayRequests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	requests := f.requests
+	f.requests = nil
+
+	return requests
+}
+
+func newTestShellyBoard(t *testing.T, device *fakeShelly, opts ShellyBoardOpts) *ShellyBoard {
+	t.Helper()
+
+	server := httptest.NewServer(device)
+	t.Cleanup(server.Close)
+
+	opts.URL = server.URL
+	if opts.Timeout == 0 {
+		opts.Timeout = time.Second
+	}
+	if opts.Poll == 0 {
+		opts.Poll = time.Hour
+	}
+
+	board, err := NewShellyBoard(opts)
+	if err != nil {
+		t.Fatalf("NewShellyBoard() error = %v", err)
+	}
+	t.Cleanup(func() { board.Halt() })
+
+	device.relayRequests()
+
+	return board
+}
+
+func TestShellyBoardRelayRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		retries uint
+		failing int
+		wantErr bool
+		want    int
+	}{
+		{"first attempt", 2, 0, false, 1},
+		{"after retries", 2, 2, false, 3},
+		{"retries exhausted", 1, 5, true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := &fakeShelly{inputs: []int{0}}
+			board := newTestShellyBoard(t, device, ShellyBoardOpts{Relays: 1, Inputs: 1, Retries: tt.retries})
+
+			device.mu.Lock()
+			device.relayFailing = tt.failing
+			device.mu.Unlock()
+
+			err := board.Relays()[0].Out(gpio.High)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Out() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if got := len(device.relayRequests()); got != tt.want {
+				t.Errorf("relay requests = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShellyBoardPress(t *testing.T) {
+	device := &fakeShelly{inputs: []int{0}}
+	board := newTestShellyBoard(t, device, ShellyBoardOpts{Relays: 2, Inputs: 1})
+
+	if err := (&Hardware{}).press(board.Relays()[1], 500*time.Millisecond); err != nil {
+		t.Fatalf("press() error = %v", err)
+	}
+
+	want := []string{"/relay/1?turn=on&timer=1", "/relay/1?turn=off"}
+	if got := device.relayRequests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+}
+
+func TestShellyBoardInputs(t *testing.T) {
+	device := &fakeShelly{inputs: []int{1, 0, 1}}
+	board := newTestShellyBoard(t, device, ShellyBoardOpts{Relays: 1, Inputs: 3})
+
+	want := []gpio.Level{gpio.High, gpio.Low, gpio.High}
+	for i, input := range board.Inputs() {
+		if got := input.Read(); got != want[i] {
+			t.Errorf("input %d Read() = %s, want %s", i+1, got, want[i])
+		}
+	}
+
+	if err := board.Fault(); err != nil {
+		t.Errorf("Fault() = %v, want nil", err)
+	}
+}
+
+func TestShellyBoardMissingInputs(t *testing.T) {
+	device := &fakeShelly{inputs: []int{1}}
+	board := newTestShellyBoard(t, device, ShellyBoardOpts{Relays: 1, Inputs: 2})
+
+	if err := board.Fault(); err == nil {
+		t.Error("Fault() = nil, want an error for a device with too few inputs")
+	}
+}
+
+func TestShellyBoardFault(t *testing.T) {
+	const poll = 20 * time.Millisecond
+
+	device := &fakeShelly{inputs: []int{0}}
+	board := newTestShellyBoard(t, device, ShellyBoardOpts{Relays: 1, Inputs: 1, Poll: poll})
+
+	if err := board.Fault(); err != nil {
+		t.Fatalf("Fault() = %v, want nil", err)
+	}
+
+	device.mu.Lock()
+	device.statusDown = true
+	device.mu.Unlock()
+
+	// A missed poll is tolerated.
+	time.Sleep(poll)
+	if err := board.Fault(); err != nil {
+		t.Errorf("Fault() after a missed poll = %v, want nil", err)
+	}
+
+	waitFor(t, "a fault after three missed polls", func() bool { return board.Fault() != nil })
+
+	device.mu.Lock()
+	device.statusDown = false
+	device.inputs = []int{1}
+	device.mu.Unlock()
+
+	waitFor(t, "the fault to clear", func() bool { return board.Fault() == nil })
+
+	if got := board.Inputs()[0].Read(); got != gpio.High {
+		t.Errorf("Read() after recovering = %s, want High", got)
+	}
+}
+
+// waitFor polls cond until it is true, failing the test after a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
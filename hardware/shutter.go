@@ -1,6 +1,7 @@
 package hardware
 
 import (
+	"fmt"
 	"log"
 	"sync"
 	"time"
@@ -9,8 +10,6 @@ import (
 	"github.com/brutella/hc"
 	"github.com/brutella/hc/accessory"
 	"github.com/brutella/hc/characteristic"
-	"periph.io/x/conn/v3/gpio"
-	"periph.io/x/host/v3"
 )
 
 type shutterState int
@@ -26,36 +25,100 @@ const (
 	shutterStateOpen    shutterState = 5
 )
 
+var shutterStateName = map[shutterState]string{
+	shutterStateFault:   "fault",
+	shutterStateUnset:   "unset",
+	shutterStateStopped: "stopped",
+	shutterStateClosed:  "closed",
+	shutterStateClosing: "closing",
+	shutterStateOpening: "opening",
+	shutterStateMoving:  "moving",
+	shutterStateOpen:    "open",
+}
+
+func (st shutterState) String() string {
+	return shutterStateName[st]
+}
+
 type Shutter struct {
-	hat          *AutomationHat
-	openButton   gpio.PinOut
-	closeButton  gpio.PinOut
-	openContact  gpio.PinIn
-	closeContact gpio.PinIn
+	*Hardware
 
 	hcLock       *homekit.GarageDoorLock
 	hcLockSwitch *homekit.GarageDoorLockSwitch
 	hcOpener     *homekit.GarageDoorOpener
 	hcOpenSensor *homekit.GarageDoorOpenSensor
+	hcLight      *homekit.GarageLightSensor
 
+	auxOutputs  []*auxOutput
 	accessories []*accessory.Accessory
 
 	options      ShutterOptions
 	shutterState shutterState
+	locked       bool
 
 	mu                sync.Mutex
 	rejectSignalUntil time.Time
+	lastSignal        shutterState
+	motorExpectedBy   time.Time
+	motorStalledSince time.Time
+	closeWarning      *time.Timer
+	rejectRevert      *time.Timer
+	relockTimer       *time.Timer
+	lockHistory       []LockEvent
 }
 
 type ShutterOptions struct {
 	BaseDirectory string
+	Board         string
+	InputBoard    string
+
+	GPIORelayPins      []string
+	GPIOInputPins      []string
+	GPIORelayActiveLow bool
+
+	ExpanderBus            string
+	ExpanderAddress        uint
+	ExpanderRelayPins      []uint
+	ExpanderInputPins      []uint
+	ExpanderRelayActiveLow bool
+
+	ShellyURL       string
+	ShellyRelays    uint
+	ShellyInputs    uint
+	ShellyTimeoutMs uint
+	ShellyRetries   uint
+	ShellyPollMs    uint
+
+	MQTTBroker       string
+	MQTTClientID     string
+	MQTTUsername     string
+	MQTTPassword     string
+	MQTTRelayTopics  []string
+	MQTTInputTopics  []string
+	MQTTPayloadOn    string
+	MQTTPayloadOff   string
+	MQTTStaleSeconds uint
+
+	SerialDevice   string
+	SerialBaud     uint
+	SerialProtocol string
+	SerialRelays   uint
+
+	RecordFile string
+	ReplayFile string
+
+	SimulatorTravelMs uint
 
 	SwitchHoldMs               uint
+	DebounceMs                 uint
+	MotionBlockMs              uint
+	AllowReverseWhileMoving    bool
 	EnableHomekitLockSwitch    bool
 	EnableHomekitLockMechanism bool
 	EnableHomekitContactSensor bool
 	LockWhenClosed             bool
 	CloseWhenLocked            bool
+	AutoRelockMinutes          uint
 
 	Name           string
 	Manufacturer   string
@@ -63,40 +126,68 @@ type ShutterOptions struct {
 	SerialNumber   string
 	HomekitPinCode string
 
+	HomekitPort      uint
+	HomekitAddress   string
+	HomekitInterface string
+
 	CloseButtonRelay  uint
 	OpenButtonRelay   uint
 	CloseContactInput uint
 	OpenContactInput  uint
+
+	CloseContactActiveLow bool
+	OpenContactActiveLow  bool
+	CloseContactPull      string
+	OpenContactPull       string
+	CloseTravelMs         uint
+	OpenTravelMs          uint
+
+	LedBrightness      uint
+	LedNightBrightness uint
+	LedNightStart      string
+	LedNightEnd        string
+
+	MotorCurrentADC       uint
+	MotorRunningVolts     float64
+	MotorStartTimeoutMs   uint
+	MotorStallVolts       float64
+	MotorStallMs          uint
+	LightSensorADC        uint
+	LightSensorLuxPerVolt float64
+
+	AuxOutputs []AuxOutputOptions
+
+	CloseWarningSeconds uint
+	CloseWarningOutput  uint
+	CloseWarningRelay   uint
 }
 
 func NewShutter(opts ShutterOptions) *Shutter {
-	if _, err := host.Init(); err != nil {
-		log.Fatalf("failed to initialize periph: %v", err)
-	}
-
-	hat, err := NewAutomationHat(&AutomationHatDefaultOpts)
+	hw, err := NewHardware(opts)
 	if err != nil {
-		log.Fatalf("failed to initialize AutomationHAT: %v", err)
+		log.Fatalf("failed to initialize hardware: %v", err)
 	}
 
-	openButton, err := hat.GetRelay(opts.OpenButtonRelay)
+	shutter, err := newShutter(hw, opts)
 	if err != nil {
-		log.Fatalf("failed to setup open button (relay 1): %v", err)
-	}
-	closeButton, err := hat.GetRelay(opts.CloseButtonRelay)
-	if err != nil {
-		log.Fatalf("failed to setup close button (relay 3): %v", err)
+		log.Fatal(err)
 	}
 
-	openContact, err := hat.GetInput(opts.OpenContactInput)
-	if err != nil {
-		log.Fatalf("failed to setup open sensor (input 1): %v", err)
-	}
-	closeContact, err := hat.GetInput(opts.CloseContactInput)
+	return shutter
+}
+
+// NewShutterWithBoard returns a shutter driven by a board that is already
+// open, reporting setup failures as an error.
+func NewShutterWithBoard(board Board, opts ShutterOptions) (*Shutter, error) {
+	hw, err := NewHardwareWithBoard(board, opts)
 	if err != nil {
-		log.Fatalf("failed to setup close sensor (input 2): %v", err)
+		return nil, fmt.Errorf("failed to initialize hardware: %w", err)
 	}
 
+	return newShutter(hw, opts)
+}
+
+func newShutter(hw *Hardware, opts ShutterOptions) (*Shutter, error) {
 	info := accessory.Info{
 		Name:         opts.Name,
 		Manufacturer: opts.Manufacturer,
@@ -130,103 +221,162 @@ func NewShutter(opts ShutterOptions) *Shutter {
 		accessories = append(accessories, hcLockSwitch.Accessory)
 	}
 
-	if opts.SwitchHoldMs == 0 {
-		opts.SwitchHoldMs = 500
+	// Optional accessories are added last so the accessory IDs of the ones
+	// above do not change for existing pairings.
+	var hcLight *homekit.GarageLightSensor
+	if hw.lightSensor != nil {
+		hcLight = homekit.NewGarageLightSensor(info)
+
+		accessories = append(accessories, hcLight.Accessory)
+	}
+
+	auxOutputs, err := newAuxOutputs(hw, opts, info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize auxiliary outputs: %w", err)
+	}
+
+	for _, aux := range auxOutputs {
+		accessories = append(accessories, aux.hc.Accessory)
 	}
 
-	return &Shutter{
+	shutter := &Shutter{
 		options:      opts,
 		shutterState: shutterStateUnset,
+		lastSignal:   shutterStateUnset,
 
-		hat:          hat,
-		openButton:   openButton,
-		closeButton:  closeButton,
-		openContact:  openContact,
-		closeContact: closeContact,
+		Hardware: hw,
 
 		hcLock:       hcLock,
 		hcLockSwitch: hcLockSwitch,
 		hcOpener:     hcOpener,
 		hcOpenSensor: hcOpenSensor,
+		hcLight:      hcLight,
 
+		auxOutputs:  auxOutputs,
 		accessories: accessories,
 	}
+
+	// The HomeKit lock accessories start out secured.
+	shutter.locked = shutter.lockEnabled()
+
+	shutter.syncRelockTimeout()
+
+	return shutter, nil
 }
 
 func (s *Shutter) Run() {
-	baseDir := s.options.BaseDirectory
-	if baseDir == "" {
-		baseDir = "."
+	transport, err := s.startHomekit()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	config := hc.Config{
-		Port:        "40111",
-		Pin:         s.options.HomekitPinCode,
-		StoragePath: baseDir + "/data",
+	hc.OnTermination(func() {
+		<-transport.Stop()
+	})
+
+	transport.Start()
+
+	err = s.Halt()
+	if err != nil {
+		log.Fatalf("Failed to halt hardware: %v", err)
 	}
+}
 
-	transport, err := hc.NewIPTransport(config, s.accessories[0], s.accessories...)
+// startHomekit sets up the HomeKit transport and its request handlers and
+// starts polling the hardware. The transport is returned ready to be started.
+func (s *Shutter) startHomekit() (hc.Transport, error) {
+	code, err := LoadSetupCode(s.options)
 	if err != nil {
-		log.Panic(err)
+		return nil, fmt.Errorf("failed to load Homekit setup code: %w", err)
 	}
 
-	hc.OnTermination(func() {
-		<-transport.Stop()
-	})
+	config, err := s.options.hapConfig(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure Homekit: %w", err)
+	}
+
+	transport, err := hc.NewIPTransport(config, s.accessories[0], s.accessories...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Homekit transport: %w", err)
+	}
 
 	log.Printf("Accessories configued: %d\n", len(s.accessories))
 
 	log.Println("Setting up Homekit garage door opener handler")
 	s.hcOpener.GarageDoorOpener.TargetDoorState.OnValueRemoteUpdate(func(state int) {
+		var err error
+
 		s.mu.Lock()
 
 		switch state {
 		case characteristic.TargetDoorStateOpen:
-			s.signalOpenShutter()
+			err = s.signalOpenShutter(SourceHomekit)
 		case characteristic.TargetDoorStateClosed:
-			s.signalCloseShutter()
+			err = s.signalCloseShutter(SourceHomekit)
 		default:
 			log.Printf("Homekit GarageDoorOpener request: signal=nil [unexpected state %d]\n", state)
 		}
 
 		s.mu.Unlock()
+
+		if err != nil {
+			log.Println("Homekit GarageDoorOpener request: " + err.Error())
+		}
 	})
 
 	if s.options.EnableHomekitLockMechanism {
 		log.Println("Setting up Homekit lock mechanism handler")
 		s.hcLock.LockMechanism.LockTargetState.OnValueRemoteUpdate(func(state int) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
 			switch state {
 			case characteristic.LockTargetStateUnsecured:
-				s.signalUnlockShutter()
+				s.signalUnlockShutter(SourceHomekit)
 			case characteristic.LockTargetStateSecured:
-				s.signalLockShutter()
+				s.signalLockShutter(SourceHomekit)
 			default:
 				log.Printf("Homekit LockMechanism request: signal=nil [unexpected state %d]\n", state)
 			}
 		})
+
+		s.hcLock.AutoSecurityTimeout.OnValueRemoteUpdate(func(seconds int) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			log.Printf("Auto relock: source=%s timeout=%s\n", SourceHomekit, time.Duration(seconds)*time.Second)
+
+			// A pending relock starts over with the new timeout.
+			if s.relockTimer != nil {
+				s.startRelockTimer()
+			}
+		})
 	}
 
 	if s.options.EnableHomekitLockSwitch {
 		log.Println("Setting up Homekit lock switch handler")
 		s.hcLockSwitch.On.OnValueRemoteUpdate(func(state bool) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
 			switch state {
 			case false:
-				s.signalUnlockShutter()
+				s.signalUnlockShutter(SourceHomekit)
 			case true:
-				s.signalLockShutter()
+				s.signalLockShutter(SourceHomekit)
 			default:
 				log.Printf("Homekit Switch request: signal=nil [unexpected state %t]\n", state)
 			}
 		})
 	}
 
+	s.setupAuxOutputHandlers()
+
 	go s.pollPhysicalState()
 
-	log.Println("Starting Homekit server: pin=" + config.Pin)
-	transport.Start()
+	logAdvertisedAddresses(config)
 
-	err = s.hat.Halt()
-	if err != nil {
-		log.Fatalf("Failed to halt AutomationHAT: %v", err)
-	}
+	log.Println("Starting Homekit server: pin=" + code.Formatted())
+
+	return transport, nil
 }
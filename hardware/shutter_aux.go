@@ -0,0 +1,148 @@
+package hardware
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"vwhitteron/homekit-garage-shutter/homekit"
+
+	"github.com/brutella/hc/accessory"
+	"periph.io/x/conn/v3/gpio"
+)
+
+const (
+	AuxOutputSwitch = "switch"
+	AuxOutputOutlet = "outlet"
+)
+
+// AuxOutputOptions configures an auxiliary output, such as a garage light,
+// buzzer or beacon, that is exposed to HomeKit and can follow the shutter.
+type AuxOutputOptions struct {
+	Name   string
+	Output uint
+	Type   string
+
+	// OnWhileMoving turns the output on while the shutter is moving.
+	OnWhileMoving bool
+	// OnAfterOpenSeconds turns the output on for this long once the shutter
+	// starts opening.
+	OnAfterOpenSeconds uint
+}
+
+type auxOutput struct {
+	opts AuxOutputOptions
+	pin  Relay
+	hc   *homekit.GarageAuxOutput
+
+	manual   bool
+	timedOn  bool
+	offTimer *time.Timer
+}
+
+func newAuxOutputs(hw *Hardware, opts ShutterOptions, info accessory.Info) ([]*auxOutput, error) {
+	outputs := make([]*auxOutput, 0, len(opts.AuxOutputs))
+
+	if hw.hat == nil && len(opts.AuxOutputs) > 0 {
+		return nil, fmt.Errorf("board %s has no outputs", opts.Board)
+	}
+
+	for _, auxOpts := range opts.AuxOutputs {
+		pin, err := hw.hat.GetOutput(auxOpts.Output)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup %s (output %d): %w", auxOpts.Name, auxOpts.Output, err)
+		}
+
+		auxInfo := info
+		auxInfo.Name = auxOpts.Name
+
+		outputs = append(outputs, &auxOutput{
+			opts: auxOpts,
+			pin:  pin,
+			hc:   homekit.NewGarageAuxOutput(auxInfo, auxOpts.Type == AuxOutputOutlet),
+		})
+	}
+
+	return outputs, nil
+}
+
+func (s *Shutter) setupAuxOutputHandlers() {
+	for _, aux := range s.auxOutputs {
+		log.Printf("Setting up Homekit %s handler\n", aux.opts.Name)
+
+		aux.hc.On.OnValueRemoteUpdate(func(on bool) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			log.Printf("Homekit %s request: source=%s value=%t\n", aux.opts.Name, SourceHomekit, on)
+
+			aux.manual = on
+			if !on {
+				aux.cancelTimer()
+			}
+
+			s.applyAuxOutput(aux, SourceHomekit)
+		})
+	}
+}
+
+// updateAuxOutputs applies the coupling rules when the shutter position
+// changes. It is called by the poller with the previous and new position.
+func (s *Shutter) updateAuxOutputs(previous shutterState, position shutterState) {
+	opening := previous == shutterStateClosed && position != shutterStateClosed && position != shutterStateFault
+
+	for _, aux := range s.auxOutputs {
+		if opening && aux.opts.OnAfterOpenSeconds > 0 {
+			s.startAuxTimer(aux, time.Duration(aux.opts.OnAfterOpenSeconds)*time.Second)
+		}
+
+		s.applyAuxOutput(aux, "hardware")
+	}
+}
+
+func (s *Shutter) startAuxTimer(aux *auxOutput, d time.Duration) {
+	aux.cancelTimer()
+	aux.timedOn = true
+
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if aux.offTimer != timer {
+			return
+		}
+
+		aux.offTimer = nil
+		aux.timedOn = false
+
+		s.applyAuxOutput(aux, "timer")
+	})
+
+	aux.offTimer = timer
+}
+
+func (a *auxOutput) cancelTimer() {
+	if a.offTimer != nil {
+		a.offTimer.Stop()
+		a.offTimer = nil
+	}
+
+	a.timedOn = false
+}
+
+// applyAuxOutput drives the output from its manual state and coupling rules.
+func (s *Shutter) applyAuxOutput(aux *auxOutput, source string) {
+	moving := s.shutterState == shutterStateMoving ||
+		s.shutterState == shutterStateOpening ||
+		s.shutterState == shutterStateClosing
+
+	on := aux.manual || aux.timedOn || (aux.opts.OnWhileMoving && moving)
+
+	if err := aux.pin.Out(gpio.Level(on)); err != nil {
+		log.Printf("Error switching %s: %v", aux.opts.Name, err)
+
+		return
+	}
+
+	aux.hc.SetOn(on, source)
+}
@@ -0,0 +1,592 @@
+package hardware
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brutella/hc"
+	"periph.io/x/conn/v3/gpio"
+)
+
+const (
+	BoardAutomationHat     = "automationhat"
+	BoardAutomationHatMini = "automationhat-mini"
+	BoardGPIO              = "gpio"
+	BoardMCP23017          = ExpanderMCP23017
+	BoardPCF8574           = ExpanderPCF8574
+	BoardShelly            = "shelly"
+	BoardMQTT              = "mqtt"
+	BoardSerial            = "serial"
+	BoardReplay            = "replay"
+	BoardSimulator         = "simulator"
+)
+
+// boardSpec describes the relays and inputs that can be assigned on a board.
+type boardSpec struct {
+	relays  []uint
+	inputs  []uint
+	outputs []uint
+	analog  bool
+}
+
+var boardSpecs = map[string]boardSpec{
+	BoardAutomationHat: {
+		relays:  []uint{1, 2, 3},
+		inputs:  []uint{1, 2, 3},
+		outputs: []uint{1, 2, 3},
+		analog:  true,
+	},
+	// The Automation HAT Mini only has the relay wired to the third relay pin.
+	BoardAutomationHatMini: {
+		relays:  []uint{3},
+		inputs:  []uint{1, 2, 3},
+		outputs: []uint{1, 2, 3},
+		analog:  true,
+	},
+	// The relays and inputs of the GPIO board are set from its pin lists.
+	BoardGPIO: {},
+	// The relays and inputs of the expander boards are set from their pin
+	// lists, which are checked against the pins of the chip.
+	BoardMCP23017: {},
+	BoardPCF8574:  {},
+	// The Shelly board has as many relays and inputs as configured.
+	BoardShelly: {},
+	// The relays and inputs of the MQTT board are set from its topic lists.
+	BoardMQTT: {},
+	// The serial board only has relays, as many as configured.
+	BoardSerial: {},
+	// The replay board has the relays and inputs of the recorded board.
+	BoardReplay: {},
+	BoardSimulator: {
+		relays: []uint{1, 2, 3},
+		inputs: []uint{1, 2, 3},
+	},
+}
+
+// reloadableOptions are the options that can be changed while the shutter is
+// running. Everything else needs a restart as it affects the hardware setup or
+// the HomeKit pairing.
+var reloadableOptions = []string{
+	"SwitchHoldMs",
+	"DebounceMs",
+	"MotionBlockMs",
+	"AllowReverseWhileMoving",
+	"LockWhenClosed",
+	"CloseWhenLocked",
+	"AutoRelockMinutes",
+	"MotorRunningVolts",
+	"MotorStartTimeoutMs",
+	"MotorStallVolts",
+	"MotorStallMs",
+	"LightSensorLuxPerVolt",
+	"CloseWarningSeconds",
+}
+
+func boardNames() string {
+	names := make([]string, 0, len(boardSpecs))
+	for name := range boardSpecs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// Validate checks the options for problems that would otherwise only surface
+// once the hardware or HomeKit transport is started. All problems found are
+// reported together.
+func (o ShutterOptions) Validate() error {
+	var errs []error
+
+	if o.Name == "" {
+		errs = append(errs, errors.New("Name must not be empty"))
+	}
+
+	if o.HomekitPinCode != "" {
+		if _, err := hc.ValidatePin(o.HomekitPinCode); err != nil {
+			errs = append(errs, fmt.Errorf("HomekitPinCode %q is invalid: %w", o.HomekitPinCode, err))
+		}
+	}
+
+	if o.HomekitPort == 0 || o.HomekitPort > 65535 {
+		errs = append(errs, fmt.Errorf("HomekitPort %d must be between 1 and 65535", o.HomekitPort))
+	}
+
+	switch {
+	case o.HomekitAddress != "" && o.HomekitInterface != "":
+		errs = append(errs, errors.New("only one of HomekitAddress and HomekitInterface may be set"))
+	case o.HomekitAddress != "" && net.ParseIP(o.HomekitAddress) == nil:
+		errs = append(errs, fmt.Errorf("HomekitAddress %q is not an IP address", o.HomekitAddress))
+	}
+
+	board := o.Board
+	if board == "" {
+		board = BoardAutomationHat
+	}
+
+	if _, ok := boardSpecs[board]; !ok {
+		errs = append(errs, fmt.Errorf("Board %q is not supported (expected one of: %s)", o.Board, boardNames()))
+
+		return errors.Join(errs...)
+	}
+
+	spec, specErrs := o.boardSpec(board, true)
+	errs = append(errs, specErrs...)
+
+	// Inputs, outputs and analog inputs come from the input board when one is
+	// set, with the relays left on the main board.
+	inputBoard := board
+	if o.InputBoard != "" {
+		inputBoard = o.InputBoard
+
+		if _, ok := boardSpecs[inputBoard]; !ok {
+			errs = append(errs, fmt.Errorf("InputBoard %q is not supported (expected one of: %s)", o.InputBoard, boardNames()))
+
+			return errors.Join(errs...)
+		} else if inputBoard == board {
+			errs = append(errs, fmt.Errorf("InputBoard must differ from Board %s", board))
+		}
+
+		inputSpec, specErrs := o.boardSpec(inputBoard, false)
+		errs = append(errs, specErrs...)
+
+		spec.inputs = inputSpec.inputs
+		spec.outputs = inputSpec.outputs
+		spec.analog = inputSpec.analog
+	}
+
+	checkRelay := func(key string, relay uint) {
+		if !slices.Contains(spec.relays, relay) {
+			errs = append(errs, fmt.Errorf("%s %d is not a relay on board %s (available: %v)", key, relay, board, spec.relays))
+		}
+	}
+
+	checkInput := func(key string, input uint) {
+		if input == 0 {
+			return
+		}
+
+		if !slices.Contains(spec.inputs, input) {
+			errs = append(errs, fmt.Errorf("%s %d is not an input on board %s (available: %v)", key, input, inputBoard, spec.inputs))
+		}
+	}
+
+	checkRelay("OpenButtonRelay", o.OpenButtonRelay)
+	checkRelay("CloseButtonRelay", o.CloseButtonRelay)
+	checkInput("OpenContactInput", o.OpenContactInput)
+	checkInput("CloseContactInput", o.CloseContactInput)
+
+	if o.OpenContactInput == 0 && o.CloseContactInput == 0 {
+		errs = append(errs, errors.New("at least one of OpenContactInput and CloseContactInput must be set"))
+	}
+
+	if o.OpenContactInput != 0 && o.OpenContactInput == o.CloseContactInput {
+		errs = append(errs, fmt.Errorf("OpenContactInput and CloseContactInput must not both use input %d", o.OpenContactInput))
+	}
+
+	if o.OpenContactInput == 0 && o.OpenTravelMs == 0 {
+		errs = append(errs, errors.New("OpenTravelMs must be set when there is no open contact (OpenContactInput = 0)"))
+	}
+
+	if o.CloseContactInput == 0 && o.CloseTravelMs == 0 {
+		errs = append(errs, errors.New("CloseTravelMs must be set when there is no close contact (CloseContactInput = 0)"))
+	}
+
+	usedOutputs := map[uint]string{}
+	for i, aux := range o.AuxOutputs {
+		key := fmt.Sprintf("AuxOutputs[%d]", i)
+
+		if aux.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: Name must not be empty", key))
+		}
+
+		if aux.Type != "" && aux.Type != AuxOutputSwitch && aux.Type != AuxOutputOutlet {
+			errs = append(errs, fmt.Errorf("%s: Type %q is not supported (expected %s or %s)", key, aux.Type, AuxOutputSwitch, AuxOutputOutlet))
+		}
+
+		if !slices.Contains(spec.outputs, aux.Output) {
+			errs = append(errs, fmt.Errorf("%s: Output %d is not an output on board %s (available: %v)", key, aux.Output, inputBoard, spec.outputs))
+		} else if other, ok := usedOutputs[aux.Output]; ok {
+			errs = append(errs, fmt.Errorf("%s: Output %d is already used by %s", key, aux.Output, other))
+		}
+
+		usedOutputs[aux.Output] = key
+	}
+
+	switch {
+	case o.CloseWarningOutput != 0 && o.CloseWarningRelay != 0:
+		errs = append(errs, errors.New("only one of CloseWarningOutput and CloseWarningRelay may be set"))
+	case o.CloseWarningOutput != 0:
+		if !slices.Contains(spec.outputs, o.CloseWarningOutput) {
+			errs = append(errs, fmt.Errorf("CloseWarningOutput %d is not an output on board %s (available: %v)", o.CloseWarningOutput, inputBoard, spec.outputs))
+		} else if other, ok := usedOutputs[o.CloseWarningOutput]; ok {
+			errs = append(errs, fmt.Errorf("CloseWarningOutput %d is already used by %s", o.CloseWarningOutput, other))
+		}
+	case o.CloseWarningRelay != 0:
+		checkRelay("CloseWarningRelay", o.CloseWarningRelay)
+
+		if o.CloseWarningRelay == o.OpenButtonRelay || o.CloseWarningRelay == o.CloseButtonRelay {
+			errs = append(errs, fmt.Errorf("CloseWarningRelay %d is already used by a shutter button", o.CloseWarningRelay))
+		}
+	}
+
+	if o.CloseWarningSeconds != 0 && o.CloseWarningOutput == 0 && o.CloseWarningRelay == 0 {
+		errs = append(errs, errors.New("CloseWarningOutput or CloseWarningRelay must be set when CloseWarningSeconds is set"))
+	}
+
+	checkAnalog := func(key string, channel uint) {
+		if channel != 0 && !spec.analog {
+			errs = append(errs, fmt.Errorf("%s is not supported on board %s", key, inputBoard))
+		} else if channel > 4 {
+			errs = append(errs, fmt.Errorf("%s %d is not an ADC channel (available: [1 2 3 4])", key, channel))
+		}
+	}
+
+	checkAnalog("MotorCurrentADC", o.MotorCurrentADC)
+	checkAnalog("LightSensorADC", o.LightSensorADC)
+
+	if o.MotorCurrentADC != 0 && o.MotorCurrentADC == o.LightSensorADC {
+		errs = append(errs, fmt.Errorf("MotorCurrentADC and LightSensorADC must not both use ADC %d", o.MotorCurrentADC))
+	}
+
+	if o.MotorCurrentADC != 0 && o.MotorRunningVolts <= 0 {
+		errs = append(errs, errors.New("MotorRunningVolts must be set when MotorCurrentADC is set"))
+	}
+
+	if o.MotorStallVolts != 0 && o.MotorStallVolts <= o.MotorRunningVolts {
+		errs = append(errs, errors.New("MotorStallVolts must be above MotorRunningVolts"))
+	}
+
+	if o.LightSensorADC != 0 && o.LightSensorLuxPerVolt <= 0 {
+		errs = append(errs, errors.New("LightSensorLuxPerVolt must be set when LightSensorADC is set"))
+	}
+
+	if o.LedBrightness > 255 {
+		errs = append(errs, fmt.Errorf("LedBrightness %d must be between 0 and 255", o.LedBrightness))
+	}
+
+	if o.LedNightBrightness > 255 {
+		errs = append(errs, fmt.Errorf("LedNightBrightness %d must be between 0 and 255", o.LedNightBrightness))
+	}
+
+	if o.LedNightStart != "" {
+		if _, err := parseTimeOfDay(o.LedNightStart); err != nil {
+			errs = append(errs, fmt.Errorf("LedNightStart: %w", err))
+		}
+	}
+
+	if o.LedNightEnd != "" {
+		if _, err := parseTimeOfDay(o.LedNightEnd); err != nil {
+			errs = append(errs, fmt.Errorf("LedNightEnd: %w", err))
+		}
+	}
+
+	if _, err := parsePull(o.OpenContactPull); err != nil {
+		errs = append(errs, fmt.Errorf("OpenContactPull: %w", err))
+	}
+
+	if _, err := parsePull(o.CloseContactPull); err != nil {
+		errs = append(errs, fmt.Errorf("CloseContactPull: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// boardSpec returns the relays, inputs and outputs that can be assigned on a
+// board, checking the options the board is set up from. The relay options are
+// only required when the board provides the relays.
+func (o ShutterOptions) boardSpec(board string, relays bool) (boardSpec, []error) {
+	var errs []error
+
+	spec := boardSpecs[board]
+
+	switch board {
+	case BoardGPIO:
+		spec.relays = numberPins(len(o.GPIORelayPins))
+		spec.inputs = numberPins(len(o.GPIOInputPins))
+
+		if relays && len(o.GPIORelayPins) == 0 {
+			errs = append(errs, errors.New("GPIORelayPins must be set for board gpio"))
+		}
+
+		pins := map[string]bool{}
+		for _, pin := range slices.Concat(o.GPIORelayPins, o.GPIOInputPins) {
+			if pins[pin] {
+				errs = append(errs, fmt.Errorf("GPIO pin %s is used more than once", pin))
+			}
+
+			pins[pin] = true
+		}
+	case BoardMCP23017, BoardPCF8574:
+		spec.relays = numberPins(len(o.ExpanderRelayPins))
+		spec.inputs = numberPins(len(o.ExpanderInputPins))
+
+		if relays && len(o.ExpanderRelayPins) == 0 {
+			errs = append(errs, fmt.Errorf("ExpanderRelayPins must be set for board %s", board))
+		}
+
+		pins := uint(8)
+		addresses := []uint{0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27}
+		if board == BoardMCP23017 {
+			pins = 16
+		} else {
+			// The PCF8574A variant uses a different address range.
+			addresses = append(addresses, 0x38, 0x39, 0x3A, 0x3B, 0x3C, 0x3D, 0x3E, 0x3F)
+		}
+
+		if address := o.expanderAddress(); !slices.Contains(addresses, address) {
+			errs = append(errs, fmt.Errorf("ExpanderAddress %#x is not an address of the %s", address, board))
+		}
+
+		used := map[uint]bool{}
+		for _, pin := range slices.Concat(o.ExpanderRelayPins, o.ExpanderInputPins) {
+			if pin >= pins {
+				errs = append(errs, fmt.Errorf("expander pin %d is not a pin on the %s (available: 0-%d)", pin, board, pins-1))
+			} else if used[pin] {
+				errs = append(errs, fmt.Errorf("expander pin %d is used more than once", pin))
+			}
+
+			used[pin] = true
+		}
+	case BoardShelly:
+		spec.relays = numberPins(int(o.shellyRelays()))
+		spec.inputs = numberPins(int(o.shellyInputs()))
+
+		if u, err := url.ParseRequestURI(o.ShellyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = append(errs, fmt.Errorf("ShellyURL %q must be an http:// URL for board shelly", o.ShellyURL))
+		}
+	case BoardMQTT:
+		spec.relays = numberPins(len(o.MQTTRelayTopics))
+		spec.inputs = numberPins(len(o.MQTTInputTopics))
+
+		if o.MQTTBroker == "" {
+			errs = append(errs, errors.New("MQTTBroker must be set for board mqtt"))
+		}
+
+		if o.MQTTClientID == "" {
+			errs = append(errs, errors.New("MQTTClientID must be set for board mqtt"))
+		}
+
+		if relays && len(o.MQTTRelayTopics) == 0 {
+			errs = append(errs, errors.New("MQTTRelayTopics must be set for board mqtt"))
+		}
+
+		if o.MQTTPayloadOn == o.MQTTPayloadOff {
+			errs = append(errs, errors.New("MQTTPayloadOn and MQTTPayloadOff must differ"))
+		}
+	case BoardSerial:
+		spec.relays = numberPins(int(o.SerialRelays))
+
+		if o.SerialDevice == "" {
+			errs = append(errs, errors.New("SerialDevice must be set for board serial"))
+		}
+
+		if !serialBaudSupported(o.SerialBaud) {
+			errs = append(errs, fmt.Errorf("SerialBaud %d is not supported", o.SerialBaud))
+		}
+
+		if _, ok := serialProtocols[o.SerialProtocol]; !ok {
+			errs = append(errs, fmt.Errorf("SerialProtocol %q is not supported (expected one of: %s)", o.SerialProtocol, serialProtocolNames()))
+		}
+
+		if relays && o.SerialRelays == 0 {
+			errs = append(errs, errors.New("SerialRelays must be set for board serial"))
+		}
+	case BoardReplay:
+		header, err := readTraceHeader(o.ReplayFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ReplayFile %q is not a hardware trace: %w", o.ReplayFile, err))
+		}
+
+		spec.relays = numberPins(int(header.Relays))
+		spec.inputs = numberPins(int(header.Inputs))
+	}
+
+	return spec, errs
+}
+
+// numberPins returns the relay or input numbers for a list of pins.
+func numberPins(pins int) []uint {
+	numbers := make([]uint, 0, pins)
+	for i := range pins {
+		numbers = append(numbers, uint(i+1))
+	}
+
+	return numbers
+}
+
+// parsePull converts a pull resistor option to its periph value. An empty
+// value leaves the pin as configured by the board.
+func parsePull(pull string) (gpio.Pull, error) {
+	switch pull {
+	case "":
+		return gpio.PullNoChange, nil
+	case "up":
+		return gpio.PullUp, nil
+	case "down":
+		return gpio.PullDown, nil
+	case "none":
+		return gpio.Float, nil
+	}
+
+	return gpio.PullNoChange, fmt.Errorf("pull %q is not supported (expected up, down or none)", pull)
+}
+
+// expanderAddress returns the I2C address of the expander board.
+func (o ShutterOptions) expanderAddress() uint {
+	if o.ExpanderAddress == 0 {
+		return 0x20
+	}
+
+	return o.ExpanderAddress
+}
+
+func (o ShutterOptions) shellyRelays() uint {
+	if o.ShellyRelays == 0 {
+		return 1
+	}
+
+	return o.ShellyRelays
+}
+
+func (o ShutterOptions) shellyInputs() uint {
+	if o.ShellyInputs == 0 {
+		return 1
+	}
+
+	return o.ShellyInputs
+}
+
+// shellyTimeout returns how long a request to the Shelly device may take.
+func (o ShutterOptions) shellyTimeout() time.Duration {
+	if o.ShellyTimeoutMs == 0 {
+		return 2 * time.Second
+	}
+
+	return time.Duration(o.ShellyTimeoutMs) * time.Millisecond
+}
+
+// shellyPoll returns how often the Shelly inputs are read.
+func (o ShutterOptions) shellyPoll() time.Duration {
+	if o.ShellyPollMs == 0 {
+		return time.Second
+	}
+
+	return time.Duration(o.ShellyPollMs) * time.Millisecond
+}
+
+// SwitchHold returns how long the remote buttons are held when pressed.
+func (o ShutterOptions) SwitchHold() time.Duration {
+	if o.SwitchHoldMs == 0 {
+		return 500 * time.Millisecond
+	}
+
+	return time.Duration(o.SwitchHoldMs) * time.Millisecond
+}
+
+// debounce returns how long door requests are subject to the debounce policy
+// after a button press.
+func (o ShutterOptions) debounce() time.Duration {
+	if o.DebounceMs == 0 {
+		return 5 * time.Second
+	}
+
+	return time.Duration(o.DebounceMs) * time.Millisecond
+}
+
+// motionBlock returns how long hardware updates are ignored after a button
+// press while the shutter starts moving.
+func (o ShutterOptions) motionBlock() time.Duration {
+	if o.MotionBlockMs == 0 {
+		return 5 * time.Second
+	}
+
+	return time.Duration(o.MotionBlockMs) * time.Millisecond
+}
+
+// motorStartTimeout returns how long after a button press the motor must be
+// running.
+func (o ShutterOptions) motorStartTimeout() time.Duration {
+	if o.MotorStartTimeoutMs == 0 {
+		return 3 * time.Second
+	}
+
+	return time.Duration(o.MotorStartTimeoutMs) * time.Millisecond
+}
+
+// motorStall returns how long the motor current must stay above the stall
+// level before the shutter is reported obstructed.
+func (o ShutterOptions) motorStall() time.Duration {
+	if o.MotorStallMs == 0 {
+		return 2 * time.Second
+	}
+
+	return time.Duration(o.MotorStallMs) * time.Millisecond
+}
+
+// autoRelock returns how long after an unlock the closed shutter is locked
+// again.
+func (o ShutterOptions) autoRelock() time.Duration {
+	return time.Duration(o.AutoRelockMinutes) * time.Minute
+}
+
+// closeWarning returns how long the warning runs before a remote close.
+func (o ShutterOptions) closeWarning() time.Duration {
+	return time.Duration(o.CloseWarningSeconds) * time.Second
+}
+
+// Options returns the options the shutter is currently running with.
+func (s *Shutter) Options() ShutterOptions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.options
+}
+
+// Reconfigure applies changed options to the running shutter. Options listed in
+// reloadableOptions take effect immediately; changes to any other option are
+// logged and ignored until the next restart.
+func (s *Shutter) Reconfigure(opts ShutterOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := reflect.ValueOf(&s.options).Elem()
+	updated := reflect.ValueOf(opts)
+
+	for i := range current.NumField() {
+		key := current.Type().Field(i).Name
+
+		if reflect.DeepEqual(current.Field(i).Interface(), updated.Field(i).Interface()) {
+			continue
+		}
+
+		if !slices.Contains(reloadableOptions, key) {
+			log.Printf("Config reload: key=%s status=rejected reason=restart required\n", key)
+
+			continue
+		}
+
+		log.Printf("Config reload: key=%s old=%v new=%v status=applied\n", key, current.Field(i).Interface(), updated.Field(i).Interface())
+
+		current.Field(i).Set(updated.Field(i))
+
+		if key == "AutoRelockMinutes" {
+			s.syncRelockTimeout()
+		}
+	}
+
+	return nil
+}
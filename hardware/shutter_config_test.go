@@ -0,0 +1,27 @@
+package hardware
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateReportsEveryContactProblem(t *testing.T) {
+	opts := ShutterOptions{
+		Name:             "Garage",
+		Board:            BoardSimulator,
+		HomekitPort:      40111,
+		OpenButtonRelay:  1,
+		CloseButtonRelay: 2,
+	}
+
+	err := opts.Validate()
+	if err == nil {
+		t.Fatal("Validate() error = nil, want the contact problems")
+	}
+
+	for _, want := range []string{"at least one of OpenContactInput", "OpenTravelMs must be set", "CloseTravelMs must be set"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error = %q, want it to contain %q", err, want)
+		}
+	}
+}
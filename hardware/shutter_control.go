@@ -0,0 +1,106 @@
+package hardware
+
+import (
+	"errors"
+	"vwhitteron/homekit-garage-shutter/homekit"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+// ErrLockDisabled is returned for lock requests unless the HomeKit lock
+// mechanism or the lock switch is enabled.
+var ErrLockDisabled = errors.New("lock is not enabled")
+
+// ShutterStatus is a snapshot of the shutter state as seen by the daemon.
+type ShutterStatus struct {
+	Position string
+	Current  string
+	Target   string
+	Locked   bool
+}
+
+func (s *Shutter) Status() ShutterStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return ShutterStatus{
+		Position: s.shutterState.String(),
+		Current:  homekit.DoorStateName[s.hcOpener.CurrentDoorState.GetValue()],
+		Target:   homekit.DoorStateName[s.hcOpener.TargetDoorState.GetValue()],
+		Locked:   s.locked,
+	}
+}
+
+// Open requests the shutter to open, subject to the same checks as a HomeKit
+// request.
+func (s *Shutter) Open(source string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.signalOpenShutter(source)
+}
+
+// Close requests the shutter to close, subject to the same checks as a HomeKit
+// request.
+func (s *Shutter) Close(source string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.signalCloseShutter(source)
+}
+
+// CancelClose aborts a close that is still in its warning phase.
+func (s *Shutter) CancelClose(source string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.cancelCloseWarning(source) {
+		return errors.New("no close is waiting to start")
+	}
+
+	s.hcOpener.SyncTarget("cancelled")
+
+	return nil
+}
+
+func (s *Shutter) Lock(source string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.lockEnabled() {
+		return ErrLockDisabled
+	}
+
+	s.signalLockShutter(source)
+
+	return nil
+}
+
+func (s *Shutter) Unlock(source string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.lockEnabled() {
+		return ErrLockDisabled
+	}
+
+	s.signalUnlockShutter(source)
+
+	return nil
+}
+
+// PressRelay engages a relay for the configured switch hold time without
+// updating the HomeKit state.
+func (s *Shutter) PressRelay(relay uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Hardware.PressRelay(relay, s.options.SwitchHold())
+}
+
+func (s *Shutter) ReadInputs() []gpio.Level {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Hardware.ReadInputs()
+}
@@ -0,0 +1,113 @@
+package hardware
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"path/filepath"
+	"strconv"
+
+	"github.com/brutella/hc"
+)
+
+// homekitPort returns the port the HomeKit server listens on.
+func (o ShutterOptions) homekitPort() uint {
+	if o.HomekitPort == 0 {
+		return 40111
+	}
+
+	return o.HomekitPort
+}
+
+// HomekitStoragePath returns the directory hc keeps its keys and pairings in.
+func (o ShutterOptions) HomekitStoragePath() string {
+	baseDir := o.BaseDirectory
+	if baseDir == "" {
+		baseDir = "."
+	}
+
+	return filepath.Join(baseDir, "data")
+}
+
+// hapConfig returns the HomeKit transport configuration. hc listens and answers
+// mDNS queries on all interfaces; an address set here, taken from
+// HomekitAddress or the first IPv4 address of HomekitInterface, only replaces
+// the addresses published in the mDNS records. hc names the mDNS host after
+// the accessory ID and has no setting for it.
+func (o ShutterOptions) hapConfig(code SetupCode) (hc.Config, error) {
+	config := hc.Config{
+		Port:        strconv.FormatUint(uint64(o.homekitPort()), 10),
+		Pin:         code.PinCode,
+		SetupId:     code.SetupID,
+		StoragePath: o.HomekitStoragePath(),
+	}
+
+	switch {
+	case o.HomekitAddress != "":
+		config.IP = o.HomekitAddress
+	case o.HomekitInterface != "":
+		ip, err := interfaceIPv4(o.HomekitInterface)
+		if err != nil {
+			return config, err
+		}
+
+		config.IP = ip.String()
+	}
+
+	return config, nil
+}
+
+func interfaceIPv4(name string) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %w", name, err)
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %w", name, err)
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+	}
+
+	return nil, fmt.Errorf("interface %s has no IPv4 address", name)
+}
+
+// logAdvertisedAddresses logs the addresses published in the mDNS records: the
+// configured address, or otherwise the addresses of every interface that is up
+// and supports multicast, as the mDNS responder uses.
+func logAdvertisedAddresses(config hc.Config) {
+	if config.IP != "" {
+		log.Printf("Homekit mDNS: address=%s port=%s\n", config.IP, config.Port)
+
+		return
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		log.Printf("Homekit mDNS: error=%q\n", err)
+
+		return
+	}
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				log.Printf("Homekit mDNS: interface=%s address=%s port=%s\n", iface.Name, ipNet.IP, config.Port)
+			}
+		}
+	}
+}
@@ -0,0 +1,501 @@
+package hardware
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brutella/hc/characteristic"
+	"github.com/brutella/hc/crypto"
+	"github.com/brutella/hc/crypto/chacha20poly1305"
+	"github.com/brutella/hc/db"
+	"github.com/brutella/hc/hap"
+	"github.com/brutella/hc/hap/pair"
+	"github.com/brutella/hc/util"
+)
+
+// hapEventHeader marks the HAP event messages once they have been rewritten
+// into HTTP responses so they can be parsed with net/http.
+const hapEventHeader = "X-Hap-Event"
+
+var fastPolling sync.Once
+
+// startHAPShutter runs a shutter on a simulated door with its HomeKit server on
+// a free port, returning the shutter, the door and the server address.
+func startHAPShutter(t *testing.T, opts ShutterOptions) (*Shutter, *SimulatorBoard, string) {
+	t.Helper()
+
+	fastPolling.Do(func() { pollInterval = 20 * time.Millisecond })
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("finding a free port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	opts.BaseDirectory = t.TempDir()
+	opts.HomekitPort = uint(port)
+	opts.OpenButtonRelay = 1
+	opts.CloseButtonRelay = 2
+	opts.OpenContactInput = 1
+	opts.CloseContactInput = 2
+	opts.SwitchHoldMs = 1
+	opts.MotionBlockMs = 50
+
+	door := NewSimulatorBoard(SimulatorBoardOpts{
+		OpenRelay:  1,
+		CloseRelay: 2,
+		OpenInput:  1,
+		CloseInput: 2,
+		Travel:     100 * time.Millisecond,
+	})
+
+	s, err := NewShutterWithBoard(door, opts)
+	if err != nil {
+		t.Fatalf("NewShutterWithBoard() error = %v", err)
+	}
+
+	transport, err := s.startHomekit()
+	if err != nil {
+		t.Fatalf("startHomekit() error = %v", err)
+	}
+
+	go transport.Start()
+	t.Cleanup(func() { <-transport.Stop() })
+
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+
+	waitFor(t, "the HomeKit server to listen", func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+
+		return err == nil
+	})
+
+	return s, door, addr
+}
+
+// hapClient is a HomeKit controller with a verified, encrypted session to an
+// accessory server.
+type hapClient struct {
+	conn      net.Conn
+	session   crypto.Cryptographer
+	responses chan *http.Response
+	events    chan hapCharacteristic
+}
+
+type hapCharacteristic struct {
+	AID   uint64 `json:"aid"`
+	IID   uint64 `json:"iid"`
+	Value any    `json:"value,omitempty"`
+	Ev    *bool  `json:"ev,omitempty"`
+}
+
+// pairHAPClient pairs a new controller with the accessory server using its
+// setup code and opens a verified session.
+func pairHAPClient(t *testing.T, s *Shutter, addr string) *hapClient {
+	t.Helper()
+
+	code, err := LoadSetupCode(s.options)
+	if err != nil {
+		t.Fatalf("LoadSetupCode() error = %v", err)
+	}
+
+	database, err := db.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("creating controller database: %v", err)
+	}
+
+	controller, err := hap.NewDevice("test-controller", database)
+	if err != nil {
+		t.Fatalf("creating controller: %v", err)
+	}
+
+	// hc occasionally sends its SRP public key a byte short, which its own
+	// client rejects, so the pairing is retried with a new key.
+	for attempt := 1; ; attempt++ {
+		err := pairSetup(addr, code.Formatted(), controller, database)
+		if err == nil {
+			break
+		}
+
+		if attempt == 3 {
+			t.Fatalf("pair-setup: %v", err)
+		}
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("connecting to %s: %v", addr, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	reader := bufio.NewReader(conn)
+	verify := pair.NewVerifySession()
+
+	// M1 and M2: exchange the session public keys.
+	start := util.NewTLV8Container()
+	start.SetByte(pair.TagSequence, pair.VerifyStepStartRequest.Byte())
+	start.SetBytes(pair.TagPublicKey, verify.PublicKey[:])
+
+	in := plainPairVerify(t, conn, reader, start)
+
+	var accessoryKey [32]byte
+	copy(accessoryKey[:], in.GetBytes(pair.TagPublicKey))
+	verify.GenerateSharedKeyWithOtherPublicKey(accessoryKey)
+
+	if err := verify.SetupEncryptionKey([]byte("Pair-Verify-Encrypt-Salt"), []byte("Pair-Verify-Encrypt-Info")); err != nil {
+		t.Fatalf("pair-verify: %v", err)
+	}
+
+	// M3 and M4: prove the controller identity.
+	var material []byte
+	material = append(material, verify.PublicKey[:]...)
+	material = append(material, controller.Name()...)
+	material = append(material, verify.OtherPublicKey[:]...)
+
+	signature, err := crypto.ED25519Signature(controller.PrivateKey(), material)
+	if err != nil {
+		t.Fatalf("pair-verify: %v", err)
+	}
+
+	identity := util.NewTLV8Container()
+	identity.SetString(pair.TagUsername, controller.Name())
+	identity.SetBytes(pair.TagSignature, signature)
+
+	encrypted, mac, err := chacha20poly1305.EncryptAndSeal(verify.EncryptionKey[:], []byte("PV-Msg03"), identity.BytesBuffer().Bytes(), nil)
+	if err != nil {
+		t.Fatalf("pair-verify: %v", err)
+	}
+
+	finish := util.NewTLV8Container()
+	finish.SetByte(pair.TagSequence, pair.VerifyStepFinishRequest.Byte())
+	finish.SetBytes(pair.TagEncryptedData, append(encrypted, mac[:]...))
+
+	if in := plainPairVerify(t, conn, reader, finish); in.GetByte(pair.TagErrCode) != 0 {
+		t.Fatalf("pair-verify: error code %d", in.GetByte(pair.TagErrCode))
+	}
+
+	session, err := crypto.NewSecureClientSessionFromSharedKey(verify.SharedKey)
+	if err != nil {
+		t.Fatalf("creating session: %v", err)
+	}
+
+	c := &hapClient{
+		conn:      conn,
+		session:   session,
+		responses: make(chan *http.Response, 16),
+		events:    make(chan hapCharacteristic, 64),
+	}
+
+	go c.read(reader)
+
+	return c
+}
+
+// pairSetup runs the pair setup exchange on a new connection, which gets a
+// new pair setup session on the accessory.
+func pairSetup(addr string, pin string, controller hap.Device, database db.Database) error {
+	transport := &http.Transport{}
+	defer transport.CloseIdleConnections()
+
+	client := &http.Client{Transport: transport}
+	setup := pair.NewSetupClientController(pin, controller, database)
+
+	for req := setup.InitialPairingRequest(); req != nil; {
+		resp, err := client.Post("http://"+addr+"/pair-setup", hap.HTTPContentTypePairingTLV8, req)
+		if err != nil {
+			return err
+		}
+
+		req, err = pair.HandleReaderForHandler(resp.Body, setup)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// plainPairVerify sends a pair-verify request before the session is
+// encrypted and returns the TLV8 response.
+func plainPairVerify(t *testing.T, conn net.Conn, reader *bufio.Reader, out util.Container) util.Container {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, "http://"+conn.RemoteAddr().String()+"/pair-verify", out.BytesBuffer())
+	if err != nil {
+		t.Fatalf("pair-verify: %v", err)
+	}
+	req.Header.Set("Content-Type", hap.HTTPContentTypePairingTLV8)
+
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("pair-verify: %v", err)
+	}
+
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		t.Fatalf("pair-verify: %v", err)
+	}
+	defer resp.Body.Close()
+
+	in, err := util.NewTLV8ContainerFromReader(resp.Body)
+	if err != nil {
+		t.Fatalf("pair-verify: %v", err)
+	}
+
+	return in
+}
+
+// read decrypts the messages from the accessory, passing responses and
+// characteristic events to their channels.
+func (c *hapClient) read(reader *bufio.Reader) {
+	pr, pw := io.Pipe()
+
+	go func() {
+		for {
+			message, err := c.session.Decrypt(reader)
+			if err != nil {
+				pw.CloseWithError(err)
+
+				return
+			}
+
+			data, _ := io.ReadAll(message)
+			if rest, ok := bytes.CutPrefix(data, []byte("EVENT/1.0 200 OK\r\n")); ok {
+				data = append([]byte("HTTP/1.0 200 OK\r\n"+hapEventHeader+": true\r\n"), rest...)
+			}
+
+			pw.Write(data)
+		}
+	}()
+
+	messages := bufio.NewReader(pr)
+	for {
+		resp, err := http.ReadResponse(messages, nil)
+		if err != nil {
+			close(c.responses)
+
+			return
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		if resp.Header.Get(hapEventHeader) == "" {
+			c.responses <- resp
+
+			continue
+		}
+
+		var event struct {
+			Characteristics []hapCharacteristic `json:"characteristics"`
+		}
+		if err := json.Unmarshal(body, &event); err == nil {
+			for _, char := range event.Characteristics {
+				c.events <- char
+			}
+		}
+	}
+}
+
+// put writes characteristics over the encrypted session.
+func (c *hapClient) put(t *testing.T, chars ...hapCharacteristic) {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]any{"characteristics": chars})
+	if err != nil {
+		t.Fatalf("encoding characteristics: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, "http://"+c.conn.RemoteAddr().String()+"/characteristics", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("PUT /characteristics: %v", err)
+	}
+	req.Header.Set("Content-Type", hap.HTTPContentTypeHAPJson)
+
+	var plain bytes.Buffer
+	if err := req.Write(&plain); err != nil {
+		t.Fatalf("PUT /characteristics: %v", err)
+	}
+
+	encrypted, err := c.session.Encrypt(&plain)
+	if err != nil {
+		t.Fatalf("PUT /characteristics: %v", err)
+	}
+
+	if _, err := io.Copy(c.conn, encrypted); err != nil {
+		t.Fatalf("PUT /characteristics: %v", err)
+	}
+
+	select {
+	case resp, ok := <-c.responses:
+		if !ok {
+			t.Fatal("PUT /characteristics: connection closed")
+		}
+
+		if resp.StatusCode != http.StatusNoContent {
+			body, _ := io.ReadAll(resp.Body)
+			t.Fatalf("PUT /characteristics: %s %s", resp.Status, body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("PUT /characteristics: no response")
+	}
+}
+
+func (c *hapClient) write(t *testing.T, char *characteristic.Characteristic, aid uint64, value any) {
+	t.Helper()
+
+	c.put(t, hapCharacteristic{AID: aid, IID: char.ID, Value: value})
+}
+
+func (c *hapClient) subscribe(t *testing.T, char *characteristic.Characteristic, aid uint64) {
+	t.Helper()
+
+	ev := true
+	c.put(t, hapCharacteristic{AID: aid, IID: char.ID, Ev: &ev})
+}
+
+// waitEvent waits for an event setting a characteristic to value.
+func (c *hapClient) waitEvent(t *testing.T, char *characteristic.Characteristic, aid uint64, value int) {
+	t.Helper()
+
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case event := <-c.events:
+			if event.AID == aid && event.IID == char.ID && fmt.Sprint(event.Value) == strconv.Itoa(value) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no event setting aid=%d iid=%d to %d", aid, char.ID, value)
+		}
+	}
+}
+
+func assertPresses(t *testing.T, door *SimulatorBoard, want ...uint) {
+	t.Helper()
+
+	if got := door.Presses(); len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+		t.Errorf("relay presses = %v, want %v", got, want)
+	}
+}
+
+func TestHAPLockedRejectsOpen(t *testing.T) {
+	s, door, addr := startHAPShutter(t, ShutterOptions{
+		EnableHomekitLockMechanism: true,
+	})
+	c := pairHAPClient(t, s, addr)
+
+	opener := s.hcOpener
+	c.subscribe(t, opener.TargetDoorState.Characteristic, opener.ID)
+
+	c.write(t, opener.TargetDoorState.Characteristic, opener.ID, characteristic.TargetDoorStateOpen)
+
+	// The rejected target is reverted to match the closed door.
+	c.waitEvent(t, opener.TargetDoorState.Characteristic, opener.ID, characteristic.TargetDoorStateClosed)
+
+	assertPresses(t, door)
+}
+
+func TestHAPDebounceRejectsReverse(t *testing.T) {
+	s, door, addr := startHAPShutter(t, ShutterOptions{
+		DebounceMs: 5000,
+	})
+	c := pairHAPClient(t, s, addr)
+
+	opener := s.hcOpener
+	c.subscribe(t, opener.TargetDoorState.Characteristic, opener.ID)
+
+	c.write(t, opener.TargetDoorState.Characteristic, opener.ID, characteristic.TargetDoorStateOpen)
+	c.write(t, opener.TargetDoorState.Characteristic, opener.ID, characteristic.TargetDoorStateClosed)
+
+	// The reverse request is rejected and the target goes back to open.
+	c.waitEvent(t, opener.TargetDoorState.Characteristic, opener.ID, characteristic.TargetDoorStateOpen)
+
+	assertPresses(t, door, 1)
+}
+
+func TestHAPLockWhenClosed(t *testing.T) {
+	s, door, addr := startHAPShutter(t, ShutterOptions{
+		EnableHomekitLockMechanism: true,
+		LockWhenClosed:             true,
+		DebounceMs:                 50,
+	})
+	c := pairHAPClient(t, s, addr)
+
+	opener := s.hcOpener
+	lock := s.hcLock
+	c.subscribe(t, opener.CurrentDoorState.Characteristic, opener.ID)
+	c.subscribe(t, lock.LockCurrentState.Characteristic, lock.ID)
+
+	c.write(t, lock.LockTargetState.Characteristic, lock.ID, characteristic.LockTargetStateUnsecured)
+	c.waitEvent(t, lock.LockCurrentState.Characteristic, lock.ID, characteristic.LockCurrentStateUnsecured)
+
+	c.write(t, opener.TargetDoorState.Characteristic, opener.ID, characteristic.TargetDoorStateOpen)
+	c.waitEvent(t, opener.CurrentDoorState.Characteristic, opener.ID, characteristic.CurrentDoorStateOpen)
+
+	c.write(t, opener.TargetDoorState.Characteristic, opener.ID, characteristic.TargetDoorStateClosed)
+	c.waitEvent(t, opener.CurrentDoorState.Characteristic, opener.ID, characteristic.CurrentDoorStateClosed)
+
+	// Reaching closed locks the shutter again.
+	c.waitEvent(t, lock.LockCurrentState.Characteristic, lock.ID, characteristic.LockCurrentStateSecured)
+
+	assertPresses(t, door, 1, 2)
+}
+
+func TestHAPCloseWhenLocked(t *testing.T) {
+	s, door, addr := startHAPShutter(t, ShutterOptions{
+		EnableHomekitLockMechanism: true,
+		CloseWhenLocked:            true,
+		DebounceMs:                 50,
+	})
+	c := pairHAPClient(t, s, addr)
+
+	opener := s.hcOpener
+	lock := s.hcLock
+	c.subscribe(t, opener.CurrentDoorState.Characteristic, opener.ID)
+	c.subscribe(t, lock.LockCurrentState.Characteristic, lock.ID)
+
+	c.write(t, lock.LockTargetState.Characteristic, lock.ID, characteristic.LockTargetStateUnsecured)
+	c.waitEvent(t, lock.LockCurrentState.Characteristic, lock.ID, characteristic.LockCurrentStateUnsecured)
+
+	c.write(t, opener.TargetDoorState.Characteristic, opener.ID, characteristic.TargetDoorStateOpen)
+	c.waitEvent(t, opener.CurrentDoorState.Characteristic, opener.ID, characteristic.CurrentDoorStateOpen)
+
+	// Locking the open shutter closes it.
+	c.write(t, lock.LockTargetState.Characteristic, lock.ID, characteristic.LockTargetStateSecured)
+	c.waitEvent(t, opener.CurrentDoorState.Characteristic, opener.ID, characteristic.CurrentDoorStateClosed)
+
+	assertPresses(t, door, 1, 2)
+}
+
+func TestHAPAutoSecurityTimeoutRestartsRelock(t *testing.T) {
+	s, _, addr := startHAPShutter(t, ShutterOptions{
+		EnableHomekitLockMechanism: true,
+		AutoRelockMinutes:          60,
+	})
+	c := pairHAPClient(t, s, addr)
+
+	lock := s.hcLock
+	c.subscribe(t, lock.LockCurrentState.Characteristic, lock.ID)
+
+	c.write(t, lock.LockTargetState.Characteristic, lock.ID, characteristic.LockTargetStateUnsecured)
+	c.waitEvent(t, lock.LockCurrentState.Characteristic, lock.ID, characteristic.LockCurrentStateUnsecured)
+
+	// Shortening the timeout applies to the relock already pending.
+	c.write(t, lock.AutoSecurityTimeout.Characteristic, lock.ID, 1)
+	c.waitEvent(t, lock.LockCurrentState.Characteristic, lock.ID, characteristic.LockCurrentStateSecured)
+}
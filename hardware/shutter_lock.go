@@ -0,0 +1,148 @@
+package hardware
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// lockHistorySize is how many lock events are kept and published to HomeKit.
+const lockHistorySize = 50
+
+// LockEvent records who or what locked or unlocked the shutter.
+type LockEvent struct {
+	Time   time.Time
+	Locked bool
+	Source string
+}
+
+func (e LockEvent) String() string {
+	action := "unlocked"
+	if e.Locked {
+		action = "locked"
+	}
+
+	return fmt.Sprintf("%s %s source=%s", e.Time.Format(time.DateTime), action, e.Source)
+}
+
+// lockEnabled reports whether the HomeKit lock mechanism or lock switch is
+// enabled. Without either the shutter is never locked.
+func (s *Shutter) lockEnabled() bool {
+	return s.hcLock != nil || s.hcLockSwitch != nil
+}
+
+// setLocked changes the lock state and reflects it on whichever HomeKit lock
+// accessories are enabled.
+func (s *Shutter) setLocked(locked bool, source string) {
+	if !s.lockEnabled() {
+		return
+	}
+
+	s.locked = locked
+
+	if locked {
+		s.hcLock.Secure()
+		s.hcLockSwitch.TurnOn()
+	} else {
+		s.hcLock.SetStateUnsecured()
+		s.hcLockSwitch.SetStateOff()
+	}
+
+	s.recordLockEvent(locked, source)
+}
+
+// LockHistory returns the recorded lock events, oldest first.
+func (s *Shutter) LockHistory() []LockEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]LockEvent(nil), s.lockHistory...)
+}
+
+func (s *Shutter) recordLockEvent(locked bool, source string) {
+	s.lockHistory = append(s.lockHistory, LockEvent{Time: time.Now(), Locked: locked, Source: source})
+	if len(s.lockHistory) > lockHistorySize {
+		s.lockHistory = s.lockHistory[len(s.lockHistory)-lockHistorySize:]
+	}
+
+	entries := make([]string, 0, len(s.lockHistory))
+	for _, event := range s.lockHistory {
+		entries = append(entries, event.String())
+	}
+
+	s.hcLock.SetLogs(entries)
+}
+
+// relockTimeout returns how long after an unlock the shutter is locked again
+// while it stays closed. It starts as AutoRelockMinutes and can be changed
+// from HomeKit through the auto security timeout. Zero disables the relock.
+func (s *Shutter) relockTimeout() time.Duration {
+	if s.hcLock == nil {
+		return s.options.autoRelock()
+	}
+
+	return time.Duration(s.hcLock.AutoSecurityTimeout.GetValue()) * time.Second
+}
+
+// syncRelockTimeout publishes the configured relock timeout to HomeKit.
+func (s *Shutter) syncRelockTimeout() {
+	if s.hcLock != nil {
+		s.hcLock.AutoSecurityTimeout.SetValue(int(s.options.autoRelock() / time.Second))
+	}
+}
+
+func (s *Shutter) startRelockTimer() {
+	s.cancelRelockTimer()
+
+	timeout := s.relockTimeout()
+	if timeout == 0 {
+		return
+	}
+
+	log.Printf("Auto relock: timeout=%s status=started\n", timeout)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(timeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// The timer may have been replaced or cancelled while waiting for
+		// the lock.
+		if s.relockTimer != timer {
+			return
+		}
+
+		s.relockTimer = nil
+
+		if s.shutterState != shutterStateClosed {
+			log.Printf("Auto relock: position=%s status=skipped reason=not closed\n", s.shutterState)
+
+			return
+		}
+
+		s.signalLockShutter(SourceAutoRelock)
+	})
+
+	s.relockTimer = timer
+}
+
+func (s *Shutter) cancelRelockTimer() {
+	if s.relockTimer == nil {
+		return
+	}
+
+	s.relockTimer.Stop()
+	s.relockTimer = nil
+}
+
+// cancelRelockOnOpen stops a pending relock once the shutter leaves the
+// closed position.
+func (s *Shutter) cancelRelockOnOpen() {
+	if s.relockTimer == nil {
+		return
+	}
+
+	log.Println("Auto relock: status=cancelled reason=opened")
+
+	s.cancelRelockTimer()
+}
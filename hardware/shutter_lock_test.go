@@ -0,0 +1,96 @@
+package hardware
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/brutella/hc/characteristic"
+)
+
+// assertLocked checks the shutter lock state and that the enabled HomeKit lock
+// accessories show the same state.
+func assertLocked(t *testing.T, s *Shutter, step string, want bool) {
+	t.Helper()
+
+	if s.locked != want {
+		t.Errorf("%s: locked = %t, want %t", step, s.locked, want)
+	}
+
+	if s.hcLock != nil {
+		current := s.hcLock.LockCurrentState.GetValue() == characteristic.LockCurrentStateSecured
+		target := s.hcLock.LockTargetState.GetValue() == characteristic.LockTargetStateSecured
+
+		if current != want || target != want {
+			t.Errorf("%s: lock mechanism current secured = %t, target secured = %t, want %t", step, current, target, want)
+		}
+	}
+
+	if s.hcLockSwitch != nil && s.hcLockSwitch.On.GetValue() != want {
+		t.Errorf("%s: lock switch on = %t, want %t", step, s.hcLockSwitch.On.GetValue(), want)
+	}
+}
+
+func TestShutterLockAccessoryCombinations(t *testing.T) {
+	tests := []struct {
+		mechanism  bool
+		lockSwitch bool
+		sensor     bool
+	}{
+		{false, false, false},
+		{true, false, false},
+		{false, true, false},
+		{true, true, false},
+		{false, false, true},
+		{true, false, true},
+		{false, true, true},
+		{true, true, true},
+	}
+
+	for _, tt := range tests {
+		opts := ShutterOptions{
+			EnableHomekitLockMechanism: tt.mechanism,
+			EnableHomekitLockSwitch:    tt.lockSwitch,
+			EnableHomekitContactSensor: tt.sensor,
+
+			OpenButtonRelay:   1,
+			CloseButtonRelay:  2,
+			OpenContactInput:  1,
+			CloseContactInput: 2,
+			SwitchHoldMs:      1,
+		}
+
+		name := fmt.Sprintf("mechanism=%t/switch=%t/sensor=%t", tt.mechanism, tt.lockSwitch, tt.sensor)
+
+		t.Run(name, func(t *testing.T) {
+			s, err := NewShutterWithBoard(NewSimulatorBoard(SimulatorBoardOpts{}), opts)
+			if err != nil {
+				t.Fatalf("NewShutterWithBoard() error = %v", err)
+			}
+
+			enabled := tt.mechanism || tt.lockSwitch
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			defer s.cancelRejectRevert()
+
+			assertLocked(t, s, "new", enabled)
+
+			s.setShutterClosed()
+			assertLocked(t, s, "closed", enabled)
+
+			if err := s.signalOpenShutter(SourceAPI); (err != nil) != enabled {
+				t.Errorf("open: error = %v, want an error only while locked", err)
+			}
+			assertLocked(t, s, "open", enabled)
+
+			s.signalUnlockShutter(SourceAPI)
+			assertLocked(t, s, "unlock", false)
+
+			s.signalLockShutter(SourceAPI)
+			assertLocked(t, s, "lock", enabled)
+
+			s.signalUnlockShutter(SourceAPI)
+			assertLocked(t, s, "unlock again", false)
+		})
+	}
+}
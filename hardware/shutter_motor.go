@@ -0,0 +1,82 @@
+package hardware
+
+import (
+	"log"
+	"time"
+)
+
+// expectMotor records that a button was pressed and the motor should start
+// running.
+func (s *Shutter) expectMotor() {
+	if s.motorCurrent == nil {
+		return
+	}
+
+	s.motorExpectedBy = time.Now().Add(s.options.motorStartTimeout())
+}
+
+// checkMotor is called by the poller to confirm the motor started after a
+// button press and to report an obstruction when it stalls.
+func (s *Shutter) checkMotor(position shutterState) {
+	if s.motorCurrent == nil {
+		return
+	}
+
+	volts, err := s.motorCurrent.Volts()
+	if err != nil {
+		log.Printf("Error reading motor current: %v", err)
+
+		return
+	}
+
+	now := time.Now()
+
+	if !s.motorExpectedBy.IsZero() {
+		if volts >= s.options.MotorRunningVolts {
+			log.Printf("Motor: source=hardware status=running volts=%.2f\n", volts)
+
+			s.motorExpectedBy = time.Time{}
+		} else if now.After(s.motorExpectedBy) {
+			log.Printf("Motor: source=hardware status=not-started volts=%.2f\n", volts)
+
+			s.leds().Flash(LedWarn, rejectWarnDuration)
+			s.motorExpectedBy = time.Time{}
+		}
+	}
+
+	if s.options.MotorStallVolts > 0 && volts >= s.options.MotorStallVolts {
+		if s.motorStalledSince.IsZero() {
+			s.motorStalledSince = now
+		}
+
+		if now.Sub(s.motorStalledSince) >= s.options.motorStall() && !s.hcOpener.ObstructionDetected.GetValue() {
+			log.Printf("Motor: source=hardware status=stalled volts=%.2f\n", volts)
+
+			s.hcOpener.SetObstructed(true)
+		}
+
+		return
+	}
+
+	s.motorStalledSince = time.Time{}
+
+	if position == shutterStateOpen || position == shutterStateClosed {
+		s.hcOpener.SetObstructed(false)
+	}
+}
+
+// updateLightLevel is called by the poller to report the garage light level.
+func (s *Shutter) updateLightLevel() {
+	if s.lightSensor == nil || s.hcLight == nil {
+		return
+	}
+
+	volts, err := s.lightSensor.Volts()
+	if err != nil {
+		log.Printf("Error reading light sensor: %v", err)
+
+		return
+	}
+
+	s.hcLight.SetLightLevel(volts * s.options.LightSensorLuxPerVolt)
+}
@@ -0,0 +1,120 @@
+package hardware
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/brutella/hc"
+	"github.com/brutella/hc/accessory"
+	"github.com/brutella/hc/util"
+)
+
+const setupIDChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// SetupCode is what a HomeKit controller needs to pair with the shutter.
+type SetupCode struct {
+	PinCode string
+	SetupID string
+}
+
+// LoadSetupCode returns the setup code the shutter pairs with. A random code
+// and setup ID are generated on first use and kept in the base directory;
+// HomekitPinCode overrides the generated code when set.
+func LoadSetupCode(opts ShutterOptions) (SetupCode, error) {
+	path := setupCodeFile(opts)
+
+	var code SetupCode
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &code); err != nil {
+			return code, fmt.Errorf("reading %s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		if code, err = generateSetupCode(); err != nil {
+			return code, err
+		}
+
+		data, _ := json.MarshalIndent(code, "", "  ")
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return code, fmt.Errorf("saving setup code: %w", err)
+		}
+
+		if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+			return code, fmt.Errorf("saving setup code: %w", err)
+		}
+
+		log.Printf("Homekit setup code: file=%s status=generated\n", path)
+	default:
+		return code, err
+	}
+
+	if opts.HomekitPinCode != "" {
+		code.PinCode = opts.HomekitPinCode
+	}
+
+	return code, nil
+}
+
+func setupCodeFile(opts ShutterOptions) string {
+	baseDir := opts.BaseDirectory
+	if baseDir == "" {
+		baseDir = "."
+	}
+
+	return filepath.Join(baseDir, "setup-code.json")
+}
+
+func generateSetupCode() (SetupCode, error) {
+	var code SetupCode
+
+	for {
+		n, err := rand.Int(rand.Reader, big.NewInt(100000000))
+		if err != nil {
+			return code, err
+		}
+
+		code.PinCode = fmt.Sprintf("%08d", n)
+
+		if _, err := hc.ValidatePin(code.PinCode); err == nil {
+			break
+		}
+	}
+
+	id := make([]byte, 4)
+	for i := range id {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(setupIDChars))))
+		if err != nil {
+			return code, err
+		}
+
+		id[i] = setupIDChars[n.Int64()]
+	}
+
+	code.SetupID = string(id)
+
+	return code, nil
+}
+
+// Formatted returns the setup code as it is entered on a controller, e.g.
+// 123-45-678.
+func (c SetupCode) Formatted() string {
+	formatted, err := hc.ValidatePin(c.PinCode)
+	if err != nil {
+		return c.PinCode
+	}
+
+	return formatted
+}
+
+// URI returns the X-HM:// setup URI that is encoded in setup QR codes.
+func (c SetupCode) URI() (string, error) {
+	return util.XHMURI(c.PinCode, c.SetupID, uint8(accessory.TypeGarageDoorOpener), []util.SetupFlag{util.SetupFlagIP})
+}
@@ -15,6 +15,8 @@ func (s *Shutter) setShutterOpen() {
 
 	s.shutterState = shutterStateOpen
 
+	s.cancelRelockOnOpen()
+
 	s.hcOpenSensor.SetStateOpen("hardware")
 
 	if !s.hcOpener.IsOpen() {
@@ -37,9 +39,9 @@ func (s *Shutter) setShutterClosed() {
 
 	s.updateState(characteristic.CurrentDoorStateClosed)
 
-	if s.options.LockWhenClosed {
-		s.hcLock.Secure()
-		s.hcLockSwitch.TurnOn()
+	if s.options.LockWhenClosed && s.lockEnabled() && !s.locked {
+		s.cancelRelockTimer()
+		s.setLocked(true, SourceLockWhenClosed)
 	}
 }
 
@@ -50,16 +52,20 @@ func (s *Shutter) setShutterMoving() {
 
 	s.shutterState = shutterStateMoving
 
+	s.cancelRelockOnOpen()
+
 	s.hcOpenSensor.SetStateOpen("hardware")
 
 	if !s.hcOpener.IsMoving() {
 		if s.hcOpener.TargetDoorState.GetValue() == characteristic.TargetDoorStateClosed {
 			log.Println("Homekit GarageDoorOpener update: source=hardware target=open current=opening")
 
+			s.cancelRejectRevert()
 			s.hcOpener.SetStateOpen(0 * time.Second)
 		} else {
 			log.Println("Homekit GarageDoorOpener update: source=hardware target=closed current=closing")
 
+			s.cancelRejectRevert()
 			s.hcOpener.SetStateClosed(0 * time.Second)
 		}
 	}
@@ -73,32 +79,80 @@ func (s *Shutter) setShutterFault() {
 	s.hcOpenSensor.SetStateClosed("hardware")
 }
 
+// pollInterval is how often the hardware is polled.
+var pollInterval = time.Second
+
 func (s *Shutter) pollPhysicalState() {
 	for {
-		time.Sleep(time.Second)
-
-		switch s.getShutterPosition() {
-		case shutterStateOpen:
-			s.setShutterOpen()
-		case shutterStateClosed:
-			s.setShutterClosed()
-		case shutterStateMoving:
-			s.setShutterMoving()
-		default:
-			s.setShutterFault()
-		}
+		time.Sleep(pollInterval)
+
+		s.poll()
 	}
 }
 
-func (s *Shutter) getShutterPosition() shutterState {
-	position := shutterStateFault
+// poll reads the shutter position once and updates the HomeKit accessories,
+// auxiliary outputs and LEDs to match.
+func (s *Shutter) poll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.shutterState
+	position := s.getShutterPosition()
+
+	switch position {
+	case shutterStateOpen:
+		s.setShutterOpen()
+	case shutterStateClosed:
+		s.setShutterClosed()
+	case shutterStateMoving:
+		s.setShutterMoving()
+	default:
+		s.setShutterFault()
+	}
+
+	s.updateAuxOutputs(previous, position)
+	s.checkMotor(position)
+	s.updateLightLevel()
 
-	if s.openContact == nil || s.closeContact == nil {
-		return position
+	s.leds().Refresh()
+	s.leds().Set(LedWarn, position == shutterStateFault || s.hcOpener.ObstructionDetected.GetValue())
+}
+
+func (h *Hardware) getShutterPosition() shutterState {
+	board, reportsFaults := h.board.(faultReporter)
+	if reportsFaults && board.Fault() != nil {
+		return shutterStateFault
+	}
+
+	position := h.getInputsPosition()
+
+	// The board can fail while the inputs are read, in which case the levels
+	// read are not the contact states.
+	if reportsFaults && board.Fault() != nil {
+		return shutterStateFault
 	}
 
-	closedContactState := s.closeContact.Read()
-	openContactState := s.openContact.Read()
+	return position
+}
+
+func (h *Hardware) getInputsPosition() shutterState {
+	switch {
+	case h.openContact != nil && h.closeContact != nil:
+		return h.getContactsPosition()
+	case h.closeContact != nil:
+		return h.inferPosition(h.closeContact, h.closeActiveLow, shutterStateClosed, shutterStateOpen, h.openTravel)
+	case h.openContact != nil:
+		return h.inferPosition(h.openContact, h.openActiveLow, shutterStateOpen, shutterStateClosed, h.closeTravel)
+	}
+
+	return shutterStateFault
+}
+
+func (h *Hardware) getContactsPosition() shutterState {
+	position := shutterStateFault
+
+	closedContactState := gpio.Level(h.closeContact.Read() != gpio.Level(h.closeActiveLow))
+	openContactState := gpio.Level(h.openContact.Read() != gpio.Level(h.openActiveLow))
 
 	btoi := map[gpio.Level]uint8{false: 0, true: 1}
 
@@ -117,6 +171,29 @@ func (s *Shutter) getShutterPosition() shutterState {
 
 	return position
 }
+
+// inferPosition works out the position when only one contact is fitted. Once
+// the contact releases the shutter is moving for the travel time, after which
+// it is assumed to have reached the other end.
+func (h *Hardware) inferPosition(contact Input, activeLow bool, made shutterState, released shutterState, travel time.Duration) shutterState {
+	if contact.Read() != gpio.Level(activeLow) {
+		h.contactMade = true
+
+		return made
+	}
+
+	if h.contactMade {
+		h.contactMade = false
+		h.contactReleasedAt = time.Now()
+	}
+
+	if time.Since(h.contactReleasedAt) < travel {
+		return shutterStateMoving
+	}
+
+	return released
+}
+
 func (s *Shutter) updateState(newState int) {
 	if s.hcOpener.IsUpdateBlocked() {
 		return
@@ -3,89 +3,176 @@ package hardware
 import (
 	"log"
 	"time"
+)
+
+const rejectWarnDuration = 2 * time.Second
+
+// rejectRevertDelay is how long a rejected target state is shown before it is
+// reverted, so the Home app visibly bounces the request back.
+const rejectRevertDelay = 1 * time.Second
 
-	"periph.io/x/conn/v3/gpio"
+// Request sources recorded in the log for door and lock requests.
+const (
+	SourceHomekit = "homekit"
+	SourceAPI     = "api"
+	SourceLock    = "lock"
+
+	SourceLockWhenClosed = "lock-when-closed"
+	SourceAutoRelock     = "auto-relock"
 )
 
-func (s *Shutter) signalCloseShutter() {
-	log.Println("Homekit GarageDoorOpener request: target=close")
+func (s *Shutter) signalCloseShutter(source string) error {
+	log.Printf("Homekit GarageDoorOpener request: source=%s target=close\n", source)
 
-	if s.rejectSignalUntil.After(time.Now()) {
-		s.hcOpener.RejectStateChange(int(s.shutterState), "close", "debounce")
+	s.leds().Blink(LedComm)
 
-		return
+	if s.closeWarning != nil {
+		log.Printf("Homekit GarageDoorOpener request: target=close status=ignored reason=warning\n")
+
+		return nil
+	}
+
+	if proceed, err := s.checkSignal(shutterStateClosing, "close"); !proceed {
+		return err
 	}
 
-	s.rejectSignalUntil = time.Now().Add(5 * time.Second)
+	s.debounceSignal(shutterStateClosing)
+
+	s.closeShutter(source, func() {
+		s.shutterState = shutterStateClosing
 
-	log.Println("Shutter remote: signal=close")
-	s.pressButton(s.closeButton)
+		s.cancelRejectRevert()
+		s.hcOpener.SetStateClosed(s.options.motionBlock())
+	})
 
-	s.shutterState = shutterStateClosing
+	if s.closeWarning != nil {
+		s.cancelRejectRevert()
+		s.hcOpener.SetTargetClosed()
+	}
 
-	s.hcOpener.SetStateClosed(5 * time.Second)
+	return nil
 }
 
-func (s *Shutter) signalOpenShutter() {
-	log.Println("Homekit GarageDoorOpener request: target=open")
+func (s *Shutter) signalOpenShutter(source string) error {
+	log.Printf("Homekit GarageDoorOpener request: source=%s target=open\n", source)
 
-	if s.rejectSignalUntil.After(time.Now()) {
-		s.hcOpener.RejectStateChange(int(s.shutterState), "open", "debounce")
+	s.leds().Blink(LedComm)
 
-		return
-	} else if s.hcLock.IsLocked() {
-		s.hcOpener.RejectStateChange(int(s.shutterState), "open", "locked")
+	if s.cancelCloseWarning(source) {
+		s.hcOpener.SyncTarget("cancelled")
 
-		return
+		return nil
+	}
+
+	if proceed, err := s.checkSignal(shutterStateOpening, "open"); !proceed {
+		return err
+	} else if s.locked {
+		return s.rejectSignal("open", "locked")
 	}
 
-	s.rejectSignalUntil = time.Now().Add(5 * time.Second)
+	s.debounceSignal(shutterStateOpening)
 
-	log.Println("Shutter remote: signal=open")
+	log.Printf("Shutter remote: source=%s signal=open\n", source)
 	s.pressButton(s.openButton)
+	s.expectMotor()
 
 	s.shutterState = shutterStateOpening
 
-	s.hcOpener.SetStateOpen(5 * time.Second)
+	s.cancelRejectRevert()
+	s.hcOpener.SetStateOpen(s.options.motionBlock())
+
+	return nil
 }
 
-func (s *Shutter) signalLockShutter() {
-	log.Println("Homekit LockMechanism request: signal=lock")
+// checkSignal applies the debounce policy to a door request. A request in the
+// same direction as the last one is ignored silently while the debounce window
+// is open; a request in the reverse direction is rejected unless the shutter is
+// configured to allow reversing while moving.
+func (s *Shutter) checkSignal(direction shutterState, target string) (bool, error) {
+	if !s.rejectSignalUntil.After(time.Now()) {
+		return true, nil
+	}
+
+	if direction == s.lastSignal {
+		log.Printf("Homekit GarageDoorOpener request: target=%s status=ignored reason=repeated\n", target)
 
-	s.hcLock.Secure()
+		return false, nil
+	}
 
-	s.hcLockSwitch.TurnOn()
+	if s.options.AllowReverseWhileMoving {
+		log.Printf("Homekit GarageDoorOpener request: target=%s status=accepted reason=reverse\n", target)
 
-	if s.options.CloseWhenLocked {
-		log.Println("Shutter remote: source=lock signal=close")
-		s.pressButton(s.closeButton)
+		return true, nil
 	}
+
+	return false, s.rejectSignal(target, "debounce")
 }
 
-func (s *Shutter) signalUnlockShutter() {
-	log.Println("Homekit LockMechanism request: signal=unlock")
+// rejectSignal refuses a door request, flashing the warning LED. The target
+// state is reverted to match the door after a short delay without blocking the
+// caller; a later accepted request cancels the pending revert.
+func (s *Shutter) rejectSignal(target string, reason string) error {
+	s.leds().Flash(LedWarn, rejectWarnDuration)
 
-	s.hcLock.SetStateUnsecured()
+	s.cancelRejectRevert()
 
-	s.hcLockSwitch.On.UpdateValue(false)
-}
+	var timer *time.Timer
+	timer = time.AfterFunc(rejectRevertDelay, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.rejectRevert != timer {
+			return
+		}
 
-func (s *Shutter) pressButton(button gpio.PinOut) {
-	duration := time.Duration(s.options.SwitchHoldMs) * time.Millisecond
+		s.rejectRevert = nil
+		s.hcOpener.SyncTarget("reverted")
+	})
 
-	err := button.Out(true)
-	if err != nil {
-		log.Printf("Error engaging relay %q: %v", button.Name(), err)
+	s.rejectRevert = timer
+
+	return s.hcOpener.RejectStateChange(target, reason)
+}
 
+func (s *Shutter) cancelRejectRevert() {
+	if s.rejectRevert == nil {
 		return
 	}
 
-	time.Sleep(duration)
+	s.rejectRevert.Stop()
+	s.rejectRevert = nil
+}
+
+func (s *Shutter) debounceSignal(direction shutterState) {
+	s.lastSignal = direction
+	s.rejectSignalUntil = time.Now().Add(s.options.debounce())
+}
 
-	err = button.Out(false)
-	if err != nil {
-		log.Printf("Error releasing relay %q: %v", button.Name(), err)
+func (s *Shutter) signalLockShutter(source string) {
+	log.Printf("Homekit LockMechanism request: source=%s signal=lock\n", source)
 
-		return
+	s.leds().Blink(LedComm)
+
+	s.cancelRelockTimer()
+	s.setLocked(true, source)
+
+	// An automatic relock only locks; it never starts the shutter moving.
+	if s.options.CloseWhenLocked && s.closeWarning == nil && source != SourceAutoRelock {
+		s.closeShutter(SourceLock, func() {})
+	}
+}
+
+func (s *Shutter) signalUnlockShutter(source string) {
+	log.Printf("Homekit LockMechanism request: source=%s signal=unlock\n", source)
+
+	s.leds().Blink(LedComm)
+
+	s.setLocked(false, source)
+	s.startRelockTimer()
+}
+
+func (s *Shutter) pressButton(button Relay) {
+	if err := s.press(button, s.options.SwitchHold()); err != nil {
+		log.Printf("Error pressing button: %v", err)
 	}
 }
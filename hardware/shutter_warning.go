@@ -0,0 +1,73 @@
+package hardware
+
+import (
+	"log"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+// closeShutter presses the close button. When a close warning is configured
+// the warning output is driven first and the button is only pressed once the
+// warning has finished, unless it is cancelled. pressed is called with s.mu
+// held after the button has been pressed.
+func (s *Shutter) closeShutter(source string, pressed func()) {
+	warning := s.options.closeWarning()
+	if warning == 0 || s.warningPin == nil {
+		s.pressCloseButton(source)
+		pressed()
+
+		return
+	}
+
+	log.Printf("Shutter remote: source=%s signal=close status=warning duration=%s\n", source, warning)
+
+	s.setCloseWarning(true)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(warning, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.closeWarning != timer {
+			return
+		}
+
+		s.closeWarning = nil
+		s.setCloseWarning(false)
+
+		s.pressCloseButton(source)
+		pressed()
+	})
+
+	s.closeWarning = timer
+}
+
+// cancelCloseWarning aborts a close that is still in its warning phase and
+// reports whether there was one.
+func (s *Shutter) cancelCloseWarning(source string) bool {
+	if s.closeWarning == nil {
+		return false
+	}
+
+	log.Printf("Shutter remote: source=%s signal=close status=cancelled\n", source)
+
+	s.closeWarning.Stop()
+	s.closeWarning = nil
+	s.setCloseWarning(false)
+
+	return true
+}
+
+func (s *Shutter) pressCloseButton(source string) {
+	log.Printf("Shutter remote: source=%s signal=close\n", source)
+
+	s.pressButton(s.closeButton)
+	s.expectMotor()
+}
+
+func (s *Shutter) setCloseWarning(on bool) {
+	if err := s.warningPin.Out(gpio.Level(on)); err != nil {
+		log.Printf("Error switching close warning: %v", err)
+	}
+}
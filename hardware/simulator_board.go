@@ -0,0 +1,174 @@
+package hardware
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+type SimulatorBoardOpts struct {
+	// OpenRelay and CloseRelay are the relays that move the simulated door,
+	// and OpenInput and CloseInput the inputs its contacts are read on.
+	OpenRelay  uint
+	CloseRelay uint
+	OpenInput  uint
+	CloseInput uint
+	// OpenActiveLow and CloseActiveLow make a contact read low rather than
+	// high when the door is at its end, like normally closed contacts.
+	OpenActiveLow  bool
+	CloseActiveLow bool
+	// Travel is how long the door takes to fully open or close.
+	Travel time.Duration
+}
+
+// SimulatorBoard is a board with three relays and three inputs wired to a
+// simulated door, so the daemon can run without hardware. The door starts
+// closed and moves while the relays are pressed; pressing a relay moves it
+// towards that end, reversing it if it is travelling the other way. When the
+// open and close relays are the same single button, a press reverses a moving
+// door and otherwise moves it towards the other end. The contacts are asserted
+// when the door is at their end.
+type SimulatorBoard struct {
+	opts SimulatorBoardOpts
+
+	mu        sync.Mutex
+	position  float64 // 0 is closed, 1 is open
+	direction float64
+	movedAt   time.Time
+	presses   []uint
+
+	relays []Relay
+	inputs []Input
+}
+
+func NewSimulatorBoard(opts SimulatorBoardOpts) *SimulatorBoard {
+	b := &SimulatorBoard{opts: opts}
+
+	for relay := uint(1); relay <= 3; relay++ {
+		b.relays = append(b.relays, &simulatorRelay{board: b, index: relay})
+	}
+
+	for input := uint(1); input <= 3; input++ {
+		b.inputs = append(b.inputs, &simulatorInput{board: b, index: input})
+	}
+
+	return b
+}
+
+func (b *SimulatorBoard) Relays() []Relay {
+	return b.relays
+}
+
+func (b *SimulatorBoard) Inputs() []Input {
+	return b.inputs
+}
+
+func (b *SimulatorBoard) Halt() error {
+	return nil
+}
+
+// Presses returns the relays pressed so far, in order.
+func (b *SimulatorBoard) Presses() []uint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]uint(nil), b.presses...)
+}
+
+// move brings the door position up to date.
+func (b *SimulatorBoard) move() {
+	now := time.Now()
+
+	if b.direction != 0 && b.opts.Travel > 0 {
+		b.position += b.direction * float64(now.Sub(b.movedAt)) / float64(b.opts.Travel)
+	} else {
+		b.position += b.direction
+	}
+
+	if b.position <= 0 || b.position >= 1 {
+		b.position = min(max(b.position, 0), 1)
+		b.direction = 0
+	}
+
+	b.movedAt = now
+}
+
+func (b *SimulatorBoard) press(relay uint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.move()
+	b.presses = append(b.presses, relay)
+
+	switch {
+	case relay == b.opts.OpenRelay && relay == b.opts.CloseRelay:
+		if b.direction != 0 {
+			b.direction = -b.direction
+		} else if b.position >= 1 {
+			b.direction = -1
+		} else {
+			b.direction = 1
+		}
+	case relay == b.opts.OpenRelay:
+		b.direction = 1
+	case relay == b.opts.CloseRelay:
+		b.direction = -1
+	default:
+		return
+	}
+
+	log.Printf("Simulator: relay=%d position=%.0f%% direction=%+.0f\n", relay, b.position*100, b.direction)
+}
+
+func (b *SimulatorBoard) read(input uint) gpio.Level {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.move()
+
+	switch input {
+	case b.opts.OpenInput:
+		return gpio.Level(b.position == 1) != gpio.Level(b.opts.OpenActiveLow)
+	case b.opts.CloseInput:
+		return gpio.Level(b.position == 0) != gpio.Level(b.opts.CloseActiveLow)
+	}
+
+	return gpio.Low
+}
+
+type simulatorRelay struct {
+	board *SimulatorBoard
+	index uint
+	level gpio.Level
+}
+
+func (r *simulatorRelay) Name() string {
+	return fmt.Sprintf("simulator relay %d", r.index)
+}
+
+// Out moves the door when the relay is pressed.
+func (r *simulatorRelay) Out(l gpio.Level) error {
+	if l && !r.level {
+		r.board.press(r.index)
+	}
+
+	r.level = l
+
+	return nil
+}
+
+type simulatorInput struct {
+	board *SimulatorBoard
+	index uint
+}
+
+func (i *simulatorInput) Name() string {
+	return fmt.Sprintf("simulator input %d", i.index)
+}
+
+func (i *simulatorInput) Read() gpio.Level {
+	return i.board.read(i.index)
+}
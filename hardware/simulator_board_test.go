@@ -0,0 +1,81 @@
+package hardware
+
+import (
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+// pressSimulator presses and releases a simulator relay.
+func pressSimulator(t *testing.T, b *SimulatorBoard, relay uint) {
+	t.Helper()
+
+	if err := (&Hardware{}).press(b.Relays()[relay-1], 0); err != nil {
+		t.Fatalf("press() error = %v", err)
+	}
+}
+
+func assertSimulatorInputs(t *testing.T, b *SimulatorBoard, open, closed gpio.Level) {
+	t.Helper()
+
+	if got := b.Inputs()[0].Read(); got != open {
+		t.Errorf("open input = %s, want %s", got, open)
+	}
+
+	if got := b.Inputs()[1].Read(); got != closed {
+		t.Errorf("close input = %s, want %s", got, closed)
+	}
+}
+
+func TestSimulatorBoardButtons(t *testing.T) {
+	b := NewSimulatorBoard(SimulatorBoardOpts{OpenRelay: 1, CloseRelay: 2, OpenInput: 1, CloseInput: 2})
+
+	assertSimulatorInputs(t, b, gpio.Low, gpio.High)
+
+	pressSimulator(t, b, 1)
+	assertSimulatorInputs(t, b, gpio.High, gpio.Low)
+
+	pressSimulator(t, b, 2)
+	assertSimulatorInputs(t, b, gpio.Low, gpio.High)
+}
+
+func TestSimulatorBoardSingleButton(t *testing.T) {
+	b := NewSimulatorBoard(SimulatorBoardOpts{OpenRelay: 1, CloseRelay: 1, OpenInput: 1, CloseInput: 2})
+
+	pressSimulator(t, b, 1)
+	assertSimulatorInputs(t, b, gpio.High, gpio.Low)
+
+	pressSimulator(t, b, 1)
+	assertSimulatorInputs(t, b, gpio.Low, gpio.High)
+}
+
+func TestSimulatorBoardSingleButtonReverses(t *testing.T) {
+	b := NewSimulatorBoard(SimulatorBoardOpts{OpenRelay: 1, CloseRelay: 1, OpenInput: 1, CloseInput: 2, Travel: time.Hour})
+
+	pressSimulator(t, b, 1)
+	if b.direction != 1 {
+		t.Fatalf("direction after the first press = %+.0f, want opening", b.direction)
+	}
+
+	pressSimulator(t, b, 1)
+	if b.direction != -1 {
+		t.Errorf("direction after the second press = %+.0f, want closing", b.direction)
+	}
+}
+
+func TestSimulatorBoardActiveLow(t *testing.T) {
+	b := NewSimulatorBoard(SimulatorBoardOpts{
+		OpenRelay:      1,
+		CloseRelay:     2,
+		OpenInput:      1,
+		CloseInput:     2,
+		OpenActiveLow:  true,
+		CloseActiveLow: true,
+	})
+
+	assertSimulatorInputs(t, b, gpio.High, gpio.Low)
+
+	pressSimulator(t, b, 1)
+	assertSimulatorInputs(t, b, gpio.Low, gpio.High)
+}
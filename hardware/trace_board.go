@@ -0,0 +1,326 @@
+package hardware
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+// Trace event kinds. A trace starts with a board event giving the number of
+// relays and inputs, followed by an event for every relay write, input read
+// and fault check in the order they happened.
+const (
+	TraceBoard = "board"
+	TraceRelay = "relay"
+	TraceInput = "input"
+	TraceFault = "fault"
+)
+
+// TraceEvent is one line of a hardware trace. Relays and inputs are numbered
+// from 1; Level is true for a fault check that found a fault.
+type TraceEvent struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	Index  uint      `json:"index,omitempty"`
+	Level  bool      `json:"level"`
+	Relays uint      `json:"relays,omitempty"`
+	Inputs uint      `json:"inputs,omitempty"`
+}
+
+// RecordingBoard wraps a board, writing a trace of all its I/O as JSON lines.
+type RecordingBoard struct {
+	board Board
+	w     io.Writer
+
+	mu     sync.Mutex
+	enc    *json.Encoder
+	relays []Relay
+	inputs []Input
+}
+
+func NewRecordingBoard(board Board, w io.Writer) (*RecordingBoard, error) {
+	b := &RecordingBoard{
+		board: board,
+		w:     w,
+		enc:   json.NewEncoder(w),
+	}
+
+	for i, relay := range board.Relays() {
+		b.relays = append(b.relays, &recordingRelay{Relay: relay, board: b, index: uint(i + 1)})
+	}
+
+	for i, input := range board.Inputs() {
+		b.inputs = append(b.inputs, &recordingInput{Input: input, board: b, index: uint(i + 1)})
+	}
+
+	if err := b.enc.Encode(TraceEvent{
+		Time:   time.Now(),
+		Kind:   TraceBoard,
+		Relays: uint(len(b.relays)),
+		Inputs: uint(len(b.inputs)),
+	}); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *RecordingBoard) Relays() []Relay {
+	return b.relays
+}
+
+func (b *RecordingBoard) Inputs() []Input {
+	return b.inputs
+}
+
+func (b *RecordingBoard) Fault() error {
+	board, ok := b.board.(faultReporter)
+	if !ok {
+		return nil
+	}
+
+	err := board.Fault()
+	b.record(TraceFault, 0, err != nil)
+
+	return err
+}
+
+// Halt halts the wrapped board and closes the trace.
+func (b *RecordingBoard) Halt() error {
+	err := b.board.Halt()
+
+	if closer, ok := b.w.(io.Closer); ok {
+		err = errors.Join(err, closer.Close())
+	}
+
+	return err
+}
+
+func (b *RecordingBoard) record(kind string, index uint, level bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.enc.Encode(TraceEvent{Time: time.Now(), Kind: kind, Index: index, Level: level}); err != nil {
+		log.Printf("Hardware trace: kind=%s index=%d error=%q\n", kind, index, err)
+	}
+}
+
+type recordingRelay struct {
+	Relay
+	board *RecordingBoard
+	index uint
+}
+
+func (r *recordingRelay) Out(l gpio.Level) error {
+	r.board.record(TraceRelay, r.index, bool(l))
+
+	return r.Relay.Out(l)
+}
+
+// Engage records the relay being switched on and passes the hold time on when
+// the wrapped relay releases itself.
+func (r *recordingRelay) Engage(hold time.Duration) error {
+	r.board.record(TraceRelay, r.index, true)
+
+	return engage(r.Relay, hold)
+}
+
+type recordingInput struct {
+	Input
+	board *RecordingBoard
+	index uint
+}
+
+func (i *recordingInput) Read() gpio.Level {
+	level := i.Input.Read()
+	i.board.record(TraceInput, i.index, bool(level))
+
+	return level
+}
+
+// ReplayBoard plays back a recorded trace. Each input returns its recorded
+// reads in order, repeating the last one once they run out, and fault checks
+// do the same. Relay writes are collected for comparison with the trace.
+type ReplayBoard struct {
+	mu     sync.Mutex
+	reads  map[uint][]bool
+	faults []bool
+	last   map[uint]bool
+	fault  bool
+	writes []TraceEvent
+
+	relays []Relay
+	inputs []Input
+}
+
+func NewReplayBoard(r io.Reader) (*ReplayBoard, error) {
+	b := &ReplayBoard{
+		reads: map[uint][]bool{},
+		last:  map[uint]bool{},
+	}
+
+	header, events, err := readTrace(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		switch event.Kind {
+		case TraceInput:
+			b.reads[event.Index] = append(b.reads[event.Index], event.Level)
+		case TraceFault:
+			b.faults = append(b.faults, event.Level)
+		}
+	}
+
+	for relay := uint(1); relay <= header.Relays; relay++ {
+		b.relays = append(b.relays, &replayRelay{board: b, index: relay})
+	}
+
+	for input := uint(1); input <= header.Inputs; input++ {
+		b.inputs = append(b.inputs, &replayInput{board: b, index: input})
+	}
+
+	return b, nil
+}
+
+// OpenReplayBoard plays back the trace in a file.
+func OpenReplayBoard(path string) (*ReplayBoard, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return NewReplayBoard(f)
+}
+
+func (b *ReplayBoard) Relays() []Relay {
+	return b.relays
+}
+
+func (b *ReplayBoard) Inputs() []Input {
+	return b.inputs
+}
+
+func (b *ReplayBoard) Fault() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.faults) > 0 {
+		b.fault, b.faults = b.faults[0], b.faults[1:]
+	}
+
+	if b.fault {
+		return errors.New("recorded fault")
+	}
+
+	return nil
+}
+
+func (b *ReplayBoard) Halt() error {
+	return nil
+}
+
+// Writes returns the relay writes made so far, without timestamps.
+func (b *ReplayBoard) Writes() []TraceEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]TraceEvent(nil), b.writes...)
+}
+
+type replayRelay struct {
+	board *ReplayBoard
+	index uint
+}
+
+func (r *replayRelay) Name() string {
+	return fmt.Sprintf("replay relay %d", r.index)
+}
+
+func (r *replayRelay) Out(l gpio.Level) error {
+	r.board.mu.Lock()
+	defer r.board.mu.Unlock()
+
+	r.board.writes = append(r.board.writes, TraceEvent{Kind: TraceRelay, Index: r.index, Level: bool(l)})
+
+	return nil
+}
+
+type replayInput struct {
+	board *ReplayBoard
+	index uint
+}
+
+func (i *replayInput) Name() string {
+	return fmt.Sprintf("replay input %d", i.index)
+}
+
+func (i *replayInput) Read() gpio.Level {
+	i.board.mu.Lock()
+	defer i.board.mu.Unlock()
+
+	if reads := i.board.reads[i.index]; len(reads) > 0 {
+		i.board.last[i.index], i.board.reads[i.index] = reads[0], reads[1:]
+	}
+
+	return gpio.Level(i.board.last[i.index])
+}
+
+// readTrace parses a trace, returning its board event and the events after it.
+func readTrace(r io.Reader) (TraceEvent, []TraceEvent, error) {
+	var header TraceEvent
+	var events []TraceEvent
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		var event TraceEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return header, nil, fmt.Errorf("trace line %d: %w", line, err)
+		}
+
+		if line == 1 {
+			if event.Kind != TraceBoard {
+				return header, nil, errors.New("trace does not start with a board event")
+			}
+
+			header = event
+
+			continue
+		}
+
+		events = append(events, event)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return header, nil, err
+	}
+
+	if header.Kind != TraceBoard {
+		return header, nil, errors.New("trace is empty")
+	}
+
+	return header, events, nil
+}
+
+// readTraceHeader returns the board event of the trace in a file.
+func readTraceHeader(path string) (TraceEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return TraceEvent{}, err
+	}
+	defer f.Close()
+
+	header, _, err := readTrace(f)
+
+	return header, err
+}
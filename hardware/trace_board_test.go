@@ -0,0 +1,129 @@
+package hardware
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func traceTestOptions() ShutterOptions {
+	return ShutterOptions{
+		OpenButtonRelay:   1,
+		CloseButtonRelay:  2,
+		OpenContactInput:  1,
+		CloseContactInput: 2,
+		SwitchHoldMs:      1,
+		DebounceMs:        1,
+		MotionBlockMs:     1,
+	}
+}
+
+// runOpenClose opens and then closes the shutter, polling until it reports
+// each end of travel.
+func runOpenClose(t *testing.T, s *Shutter) {
+	t.Helper()
+
+	pollUntil := func(want shutterState) {
+		t.Helper()
+
+		for range 100 {
+			time.Sleep(5 * time.Millisecond)
+			s.poll()
+
+			if s.shutterState == want {
+				return
+			}
+		}
+
+		t.Fatalf("shutter state = %s, want %s", s.shutterState, want)
+	}
+
+	pollUntil(shutterStateClosed)
+
+	if err := s.Open(SourceAPI); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	pollUntil(shutterStateOpen)
+
+	if err := s.Close(SourceAPI); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	pollUntil(shutterStateClosed)
+}
+
+func TestReplayBoardMatchesRecording(t *testing.T) {
+	var trace bytes.Buffer
+
+	recording, err := NewRecordingBoard(NewSimulatorBoard(SimulatorBoardOpts{
+		OpenRelay:  1,
+		CloseRelay: 2,
+		OpenInput:  1,
+		CloseInput: 2,
+		Travel:     20 * time.Millisecond,
+	}), &trace)
+	if err != nil {
+		t.Fatalf("NewRecordingBoard() error = %v", err)
+	}
+
+	recorded, err := NewShutterWithBoard(recording, traceTestOptions())
+	if err != nil {
+		t.Fatalf("NewShutterWithBoard() error = %v", err)
+	}
+
+	runOpenClose(t, recorded)
+
+	_, events, err := readTrace(bytes.NewReader(trace.Bytes()))
+	if err != nil {
+		t.Fatalf("readTrace() error = %v", err)
+	}
+
+	var want []TraceEvent
+	for _, event := range events {
+		if event.Kind == TraceRelay {
+			event.Time = time.Time{}
+			want = append(want, event)
+		}
+	}
+
+	if len(want) != 4 {
+		t.Fatalf("recorded %d relay writes, want a press of the open and close relays: %+v", len(want), want)
+	}
+
+	replay, err := NewReplayBoard(bytes.NewReader(trace.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReplayBoard() error = %v", err)
+	}
+
+	replayed, err := NewShutterWithBoard(replay, traceTestOptions())
+	if err != nil {
+		t.Fatalf("NewShutterWithBoard() error = %v", err)
+	}
+
+	runOpenClose(t, replayed)
+
+	if got := replay.Writes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed relay writes = %+v, want %+v", got, want)
+	}
+}
+
+func TestReplayBoardRejectsBadTraces(t *testing.T) {
+	tests := []struct {
+		name  string
+		trace string
+	}{
+		{"empty", ""},
+		{"no board event", `{"kind":"relay","index":1,"level":true}` + "\n"},
+		{"not json", "relay 1 on\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewReplayBoard(bytes.NewBufferString(tt.trace)); err == nil {
+				t.Error("NewReplayBoard() error = nil, want an error")
+			}
+		})
+	}
+}
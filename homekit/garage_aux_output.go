@@ -0,0 +1,51 @@
+package homekit
+
+import (
+	"log"
+
+	"github.com/brutella/hc/accessory"
+	"github.com/brutella/hc/characteristic"
+	"github.com/brutella/hc/service"
+)
+
+// GarageAuxOutput is an auxiliary output such as a light, buzzer or beacon,
+// presented as either a switch or an outlet.
+type GarageAuxOutput struct {
+	*accessory.Accessory
+	On *characteristic.On
+}
+
+func NewGarageAuxOutput(info accessory.Info, outlet bool) *GarageAuxOutput {
+	acc := GarageAuxOutput{}
+
+	if outlet {
+		acc.Accessory = accessory.New(info, accessory.TypeOutlet)
+
+		svc := service.NewOutlet()
+		svc.OutletInUse.SetValue(true)
+
+		acc.On = svc.On
+		acc.Accessory.AddService(svc.Service)
+	} else {
+		acc.Accessory = accessory.New(info, accessory.TypeSwitch)
+
+		svc := service.NewSwitch()
+
+		acc.On = svc.On
+		acc.Accessory.AddService(svc.Service)
+	}
+
+	acc.On.SetValue(false)
+
+	return &acc
+}
+
+func (o *GarageAuxOutput) SetOn(on bool, source string) {
+	if o.On.GetValue() == on {
+		return
+	}
+
+	log.Printf("Homekit %s update: source=%s value=%t", o.Info.Name.GetValue(), source, on)
+
+	o.On.UpdateValue(on)
+}
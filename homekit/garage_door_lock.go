@@ -8,9 +8,16 @@ import (
 	"github.com/brutella/hc/service"
 )
 
+// logEntryType is the TLV8 type of each entry in the Logs characteristic.
+const logEntryType = 0x01
+
 type GarageDoorLock struct {
 	*accessory.Accessory
 	*service.LockMechanism
+
+	Management          *service.LockManagement
+	Logs                *characteristic.Logs
+	AutoSecurityTimeout *characteristic.LockManagementAutoSecurityTimeout
 }
 
 var lockState = map[int]string{
@@ -30,10 +37,32 @@ func NewGarageDoorLock(info accessory.Info) *GarageDoorLock {
 
 	acc.Accessory.AddService(acc.LockMechanism.Service)
 
+	acc.Management = service.NewLockManagement()
+	acc.Management.Version.SetValue("1.0")
+
+	acc.Logs = characteristic.NewLogs()
+	acc.Management.AddCharacteristic(acc.Logs.Characteristic)
+
+	acc.AutoSecurityTimeout = characteristic.NewLockManagementAutoSecurityTimeout()
+	acc.Management.AddCharacteristic(acc.AutoSecurityTimeout.Characteristic)
+
+	// Lock control point requests are not supported. The lock history is
+	// published through Logs and the relock timeout through
+	// AutoSecurityTimeout instead.
+	acc.Management.LockControlPoint.OnValueRemoteUpdate(func(b []byte) {
+		log.Printf("Homekit LockManagement request: control=%x status=ignored", b)
+	})
+
+	acc.Accessory.AddService(acc.Management.Service)
+
 	return &acc
 }
 
 func (l *GarageDoorLock) Secure() {
+	if l == nil {
+		return
+	}
+
 	current := l.LockTargetState.GetValue()
 	log.Printf("Homekit LockMechanism update: target=%s current=secured", lockState[current])
 
@@ -42,6 +71,10 @@ func (l *GarageDoorLock) Secure() {
 }
 
 func (l *GarageDoorLock) SetStateUnsecured() {
+	if l == nil {
+		return
+	}
+
 	current := l.LockTargetState.GetValue()
 	log.Printf("Homekit LockMechanism update: target=%s current=unsecured", lockState[current])
 
@@ -49,16 +82,28 @@ func (l *GarageDoorLock) SetStateUnsecured() {
 	l.LockCurrentState.UpdateValue(characteristic.LockCurrentStateUnsecured)
 }
 
-func (l *GarageDoorLock) IsLocked() bool {
-	lockState := l.LockCurrentState.GetValue()
+// SetLogs publishes the lock history, one TLV8 entry per line.
+func (l *GarageDoorLock) SetLogs(entries []string) {
+	if l == nil {
+		return
+	}
 
-	if lockState == characteristic.LockCurrentStateUnsecured {
-		log.Println("Is locked: current=unlocked")
+	var tlv []byte
 
-		return false
-	}
+	for _, entry := range entries {
+		value := []byte(entry)
+
+		// Values longer than 255 bytes are split over consecutive items of
+		// the same type.
+		for len(value) > 255 {
+			tlv = append(tlv, logEntryType, 255)
+			tlv = append(tlv, value[:255]...)
+			value = value[255:]
+		}
 
-	log.Println("Is locked: current=locked")
+		tlv = append(tlv, logEntryType, byte(len(value)))
+		tlv = append(tlv, value...)
+	}
 
-	return true
+	l.Logs.SetValue(tlv)
 }
@@ -26,12 +26,20 @@ func NewGarageDoorLockSwitch(info accessory.Info) *GarageDoorLockSwitch {
 }
 
 func (l *GarageDoorLockSwitch) TurnOn() {
+	if l == nil {
+		return
+	}
+
 	log.Println("Homekit Switch update: value=on")
 
 	l.On.UpdateValue(true)
 }
 
 func (l *GarageDoorLockSwitch) SetStateOff() {
+	if l == nil {
+		return
+	}
+
 	log.Println("Homekit Switch update: value=off")
 
 	l.On.UpdateValue(false)
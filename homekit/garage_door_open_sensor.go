@@ -27,7 +27,7 @@ func NewGarageDoorOpenSensor(info accessory.Info) *GarageDoorOpenSensor {
 }
 
 func (s *GarageDoorOpenSensor) SetStateOpen(source string) {
-	if s.IsOpen() {
+	if s == nil || s.IsOpen() {
 		return
 	}
 
@@ -37,7 +37,7 @@ func (s *GarageDoorOpenSensor) SetStateOpen(source string) {
 }
 
 func (s *GarageDoorOpenSensor) SetStateClosed(source string) {
-	if s.IsClosed() {
+	if s == nil || s.IsClosed() {
 		return
 	}
 
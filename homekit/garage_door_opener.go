@@ -1,6 +1,10 @@
+// Package homekit implements the HomeKit accessories the shutter publishes.
+// The methods of the optional accessories do nothing on a nil accessory, so
+// the shutter can update them whether or not they are enabled.
 package homekit
 
 import (
+	"fmt"
 	"log"
 	"time"
 
@@ -9,15 +13,25 @@ import (
 	"github.com/brutella/hc/service"
 )
 
-var shutterStateName = map[int]string{
-	-100: "fault",
-	-1:   "unset",
-	0:    "stopped",
-	1:    "closed",
-	2:    "closing",
-	3:    "opening",
-	4:    "moving",
-	5:    "open",
+// DoorStateName names the current door states. The target door states share
+// the values of open and closed.
+var DoorStateName = map[int]string{
+	characteristic.CurrentDoorStateOpen:    "open",
+	characteristic.CurrentDoorStateClosed:  "closed",
+	characteristic.CurrentDoorStateOpening: "opening",
+	characteristic.CurrentDoorStateClosing: "closing",
+	characteristic.CurrentDoorStateStopped: "stopped",
+}
+
+// RejectedError is returned when a door state change request is refused.
+type RejectedError struct {
+	Target  string
+	Current string
+	Reason  string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("target=%s current=%s status=rejected reason=%s", e.Target, e.Current, e.Reason)
 }
 
 type GarageDoorOpener struct {
@@ -79,13 +93,46 @@ func (o *GarageDoorOpener) SetStateOpen(seconds time.Duration) {
 	o.BlockUpdateUntil(time.Now().Add(seconds))
 }
 
-func (o *GarageDoorOpener) RejectStateChange(initial int, target string, reason string) {
-	log.Printf("Homekit GarageDoorOpener request: target=%s current=%s  status=rejected reason=%s\n", target, shutterStateName[initial], reason)
+// RejectStateChange returns the error describing a refused target state.
+// The caller is responsible for reverting the target.
+func (o *GarageDoorOpener) RejectStateChange(target string, reason string) error {
+	return &RejectedError{
+		Target:  target,
+		Current: DoorStateName[o.CurrentDoorState.GetValue()],
+		Reason:  reason,
+	}
+}
+
+// SetTargetClosed shows the door as about to close without it moving yet.
+func (o *GarageDoorOpener) SetTargetClosed() {
+	log.Printf("Homekit GarageDoorOpener update: target=closed current=%s\n", DoorStateName[o.CurrentDoorState.GetValue()])
+
+	o.TargetDoorState.UpdateValue(characteristic.TargetDoorStateClosed)
+}
+
+// SyncTarget sets the target state to match the current door state.
+func (o *GarageDoorOpener) SyncTarget(status string) {
+	current := o.CurrentDoorState.GetValue()
+
+	target := characteristic.TargetDoorStateClosed
+	if current == characteristic.CurrentDoorStateOpen || current == characteristic.CurrentDoorStateOpening {
+		target = characteristic.TargetDoorStateOpen
+	}
+
+	log.Printf("Homekit GarageDoorOpener update: target=%s current=%s status=%s\n", DoorStateName[target], DoorStateName[current], status)
+
+	o.TargetDoorState.UpdateValue(target)
+	o.CurrentDoorState.UpdateValue(current)
+}
+
+func (o *GarageDoorOpener) SetObstructed(obstructed bool) {
+	if o.ObstructionDetected.GetValue() == obstructed {
+		return
+	}
 
-	time.Sleep(1 * time.Second)
+	log.Printf("Homekit GarageDoorOpener update: obstruction=%t\n", obstructed)
 
-	o.TargetDoorState.SetValue(initial)
-	o.CurrentDoorState.SetValue(initial)
+	o.ObstructionDetected.UpdateValue(obstructed)
 }
 
 func (o *GarageDoorOpener) IsOpen() bool {
@@ -0,0 +1,42 @@
+package homekit
+
+import (
+	"log"
+	"math"
+
+	"github.com/brutella/hc/accessory"
+	"github.com/brutella/hc/service"
+)
+
+// lightLevelChange is the relative change in light level needed before an
+// update is sent, to avoid flooding HomeKit with sensor noise.
+const lightLevelChange = 0.05
+
+type GarageLightSensor struct {
+	*accessory.Accessory
+	*service.LightSensor
+}
+
+func NewGarageLightSensor(info accessory.Info) *GarageLightSensor {
+	acc := GarageLightSensor{}
+
+	acc.Accessory = accessory.New(info, accessory.TypeSensor)
+	acc.LightSensor = service.NewLightSensor()
+
+	acc.Accessory.AddService(acc.LightSensor.Service)
+
+	return &acc
+}
+
+func (s *GarageLightSensor) SetLightLevel(lux float64) {
+	lux = math.Max(s.CurrentAmbientLightLevel.GetMinValue(), math.Min(lux, s.CurrentAmbientLightLevel.GetMaxValue()))
+
+	current := s.CurrentAmbientLightLevel.GetValue()
+	if math.Abs(lux-current) <= current*lightLevelChange {
+		return
+	}
+
+	log.Printf("Homekit LightSensor update: lux=%.1f", lux)
+
+	s.CurrentAmbientLightLevel.UpdateValue(lux)
+}
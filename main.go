@@ -5,9 +5,8 @@ import (
 	"log"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
-	"time"
+	"vwhitteron/homekit-garage-shutter/control"
 	"vwhitteron/homekit-garage-shutter/hardware"
 
 	"github.com/spf13/viper"
@@ -17,10 +16,26 @@ var Version = "DEV"
 var BuildTime string
 
 func main() {
+	setupConfig()
+
+	args := os.Args[1:]
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+
+	if err := runCommand(args); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func serve(_ []string) error {
 	sigs := make(chan os.Signal, 1)
+	hups := make(chan os.Signal, 1)
 	done := make(chan bool, 1)
 
 	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(hups, syscall.SIGHUP)
 
 	go func() {
 		sig := <-sigs
@@ -28,56 +43,69 @@ func main() {
 		done <- true
 	}()
 
-	shutterOptions := &hardware.ShutterOptions{
-		BaseDirectory:              "/opt/homekit-garage-shutter",
-		EnableHomekitLockSwitch:    true,
-		EnableHomekitLockMechanism: true,
-		EnableHomekitContactSensor: true,
-		LockWhenClosed:             true,
-		CloseWhenLocked:            true,
-		SwitchHoldMs:               500,
-
-		Name:         "Garage Shutter",
-		Manufacturer: "generic",
-		Model:        "",
-		SerialNumber: "",
-
-		HomekitPinCode: "00001234",
-
-		OpenButtonRelay:   1,
-		CloseButtonRelay:  3,
-		OpenContactInput:  1,
-		CloseContactInput: 2,
+	shutterOptions, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	viper.SetEnvPrefix("HOMEBRIDGE_GARAGE_SHUTTER")
-	viper.SetEnvKeyReplacer(strings.NewReplacer(`.`, `_`))
-	viper.AutomaticEnv()
-
-	viper.SetConfigName("config")
-	viper.SetConfigType("toml")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("/boot/homekit-garage-shutter/")
-	viper.AddConfigPath("/etc/homekit-garage-shutter/")
-	viper.AddConfigPath("/opt/homekit-garage-shutter/")
-	err := viper.ReadInConfig()
-	if err != nil {
-		log.Fatal("failed to read config file: ", err)
-	} else {
-		err = viper.Unmarshal(shutterOptions)
+	if err := shutterOptions.Validate(); err != nil {
+		log.Fatalf("invalid configuration:\n%s", formatConfigError(err))
+	}
+
+	if err := version(nil); err != nil {
+		return err
+	}
+
+	shutter := hardware.NewShutter(shutterOptions)
+
+	reload := func() {
+		opts, err := unmarshalConfig()
+		if err == nil {
+			err = shutter.Reconfigure(opts)
+		}
+
 		if err != nil {
-			log.Fatal("unmarshal config: ", err)
+			log.Printf("Config reload: status=failed error:\n%s", formatConfigError(err))
 		}
 	}
 
-	if BuildTime == "" {
-		BuildTime = time.Now().Format("2006-01-02_15:04:05")
+	// File changes and SIGHUP are both handled on this one goroutine so that
+	// viper is never read by two reloads at once.
+	changes, err := watchConfigFile(viper.ConfigFileUsed())
+	if err != nil {
+		log.Printf("Config watch: status=failed error=%q\n", err)
 	}
-	fmt.Printf("homebridge-garage-shutter version %s (built %s)\n", Version, BuildTime)
 
-	shutter := hardware.NewShutter(*shutterOptions)
+	go func() {
+		for {
+			select {
+			case name := <-changes:
+				log.Printf("Config reload: source=file path=%s\n", name)
+			case <-hups:
+				log.Println("Config reload: source=SIGHUP")
+			}
+
+			if err := viper.ReadInConfig(); err != nil {
+				log.Printf("Config reload: status=failed error=%q\n", err)
+
+				continue
+			}
+
+			reload()
+		}
+	}()
+
+	server := control.NewServer(controlSocket(shutterOptions))
+	registerHandlers(server, shutter)
+
+	if err := server.Listen(); err != nil {
+		log.Fatalf("failed to open control socket: %v", err)
+	}
+	defer server.Close()
 
 	shutter.Run()
 
 	<-done
+
+	return nil
 }
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/brutella/hc/db"
+	"github.com/brutella/hc/util"
+	"rsc.io/qr"
+	"vwhitteron/homekit-garage-shutter/hardware"
+)
+
+func pairingInfo(_ []string) error {
+	opts, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	code, err := hardware.LoadSetupCode(opts)
+	if err != nil {
+		return err
+	}
+
+	uri, err := code.URI()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Setup code: %s\n", code.Formatted())
+	fmt.Printf("Setup ID:   %s\n", code.SetupID)
+	fmt.Printf("Setup URI:  %s\n\n", uri)
+
+	qrCode, err := qr.Encode(uri, qr.M)
+	if err != nil {
+		return err
+	}
+
+	printQR(os.Stdout, qrCode)
+
+	return nil
+}
+
+// printQR draws a QR code with half block characters, two modules per line,
+// in black on white so it scans whatever the terminal colours are.
+func printQR(w io.Writer, code *qr.Code) {
+	const quiet = 2
+
+	dark := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < code.Size && y < code.Size && code.Black(x, y)
+	}
+
+	for y := -quiet; y < code.Size+quiet; y += 2 {
+		var line strings.Builder
+
+		line.WriteString("\x1b[30;47m")
+
+		for x := -quiet; x < code.Size+quiet; x++ {
+			switch top, bottom := dark(x, y), dark(x, y+1); {
+			case top && bottom:
+				line.WriteString("█")
+			case top:
+				line.WriteString("▀")
+			case bottom:
+				line.WriteString("▄")
+			default:
+				line.WriteString(" ")
+			}
+		}
+
+		line.WriteString("\x1b[0m")
+
+		fmt.Fprintln(w, line.String())
+	}
+}
+
+// openPairings opens the hc storage. The accessory keeps its own keys there
+// next to the paired controllers; only the controllers are returned.
+func openPairings(opts hardware.ShutterOptions) (db.Database, []db.Entity, error) {
+	path := opts.HomekitStoragePath()
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, fmt.Errorf("no HomeKit storage: %w", err)
+	}
+
+	storage, err := util.NewFileStorage(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	database := db.NewDatabaseWithStorage(storage)
+
+	entities, err := database.Entities()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var controllers []db.Entity
+	for _, entity := range entities {
+		if len(entity.PrivateKey) == 0 {
+			controllers = append(controllers, entity)
+		}
+	}
+
+	return database, controllers, nil
+}
+
+func pairingList(_ []string) error {
+	opts, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	_, controllers, err := openPairings(opts)
+	if err != nil {
+		return err
+	}
+
+	if len(controllers) == 0 {
+		fmt.Println("not paired")
+
+		return nil
+	}
+
+	for _, controller := range controllers {
+		fmt.Println(controller.Name)
+	}
+
+	// hc accepts the permission when a pairing is added but does not store it,
+	// and lets every paired controller manage pairings.
+	fmt.Printf("\n%d paired controllers, all with admin access\n", len(controllers))
+
+	return nil
+}
+
+func pairingRemove(args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected a controller ID, see pairing list")
+	}
+
+	opts, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	// The daemon keeps sessions of paired controllers open, so the pairings
+	// are only changed while it is stopped.
+	if err := requireDaemonStopped(opts); err != nil {
+		return err
+	}
+
+	database, controllers, err := openPairings(opts)
+	if err != nil {
+		return err
+	}
+
+	for _, controller := range controllers {
+		if controller.Name == args[0] {
+			database.DeleteEntity(controller)
+
+			fmt.Printf("removed %s\n", controller.Name)
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no paired controller %q", args[0])
+}
+
+func pairingReset(args []string) error {
+	flags := flag.NewFlagSet("pairing reset", flag.ContinueOnError)
+	yes := flags.Bool("yes", false, "do not ask for confirmation")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	opts, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := requireDaemonStopped(opts); err != nil {
+		return err
+	}
+
+	path := opts.HomekitStoragePath()
+
+	prompt := fmt.Sprintf("Remove every pairing and the HomeKit identity in %s? The shutter has to be added to the Home app again.", path)
+	if !*yes && !confirm(bufio.NewReader(os.Stdin))(prompt) {
+		return errors.New("reset cancelled")
+	}
+
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+
+	fmt.Println("pairing reset, the shutter can be paired again with the code from pairing-info")
+
+	return nil
+}